@@ -1,110 +1,864 @@
 package resource
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
 )
 
+// now is replaced in tests.
+var now = time.Now
+
 // Check (business logic)
 func Check(request CheckRequest, manager Github) (CheckResponse, error) {
-	var response CheckResponse
+	ctx, cancel, err := request.Source.timeoutContext()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	response, err := check(ctx, request, []repositoryManager{{Github: manager}})
+	return response, classifyError(ctx, err)
+}
 
-	pulls, err := manager.ListOpenPullRequests()
+// CheckRepositories checks all the repositories of the source, using newGithub
+// to create a manager for each. Versions are tagged with their repository.
+func CheckRepositories(request CheckRequest, newGithub func(repository string) (Github, error)) (CheckResponse, error) {
+	ctx, cancel, err := request.Source.timeoutContext()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get last commits: %s", err)
+		return nil, err
+	}
+	defer cancel()
+	response, err := checkRepositories(ctx, request, newGithub)
+	return response, classifyError(ctx, err)
+}
+
+func checkRepositories(ctx context.Context, request CheckRequest, newGithub func(repository string) (Github, error)) (CheckResponse, error) {
+	if request.Source.SearchQuery != "" {
+		return checkSearch(ctx, request, newGithub)
 	}
-	var disableSkipCI bool
-	if request.Source.DisableCISkip != "" {
-		disableSkipCI, err = strconv.ParseBool(request.Source.DisableCISkip)
+	if len(request.Source.Repositories) == 0 {
+		manager, err := newGithub(request.Source.Repository)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse disable_ci_skip: %s", err)
+			return nil, err
+		}
+		return check(ctx, request, []repositoryManager{{Github: manager}})
+	}
+	var managers []repositoryManager
+	for _, r := range request.Source.Repositories {
+		manager, err := newGithub(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create github manager for %s: %w", r, err)
 		}
+		managers = append(managers, repositoryManager{Repository: r, Github: manager})
 	}
+	return check(ctx, request, managers)
+}
 
-Loop:
+// checkSearch checks the pull requests found by the search query of the source.
+// The search uses a manager without a repository, and the pull requests are
+// checked with a manager for each of the repositories in the results.
+func checkSearch(ctx context.Context, request CheckRequest, newGithub func(repository string) (Github, error)) (CheckResponse, error) {
+	source := request.Source
+	source.ApplyDefaults()
+	searcher, err := newGithub("")
+	if err != nil {
+		return nil, err
+	}
+	// Search qualifiers are combined with AND, so each of the states is searched for separately.
+	var pulls []*PullRequest
+	for _, s := range source.States {
+		found, err := searcher.SearchPullRequests(ctx, searchStateQualifiers[strings.ToUpper(s)]+" "+source.SearchQuery, source.MaxCommitsPerPR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search pull requests: %w", err)
+		}
+		pulls = append(pulls, found...)
+	}
+	var managers []repositoryManager
+	index := make(map[string]int)
+	for _, p := range pulls {
+		r := p.Repository.NameWithOwner
+		i, ok := index[r]
+		if !ok {
+			manager, err := newGithub(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create github manager for %s: %w", r, err)
+			}
+			i = len(managers)
+			index[r] = i
+			managers = append(managers, repositoryManager{Repository: r, Github: manager})
+		}
+		managers[i].Listed = append(managers[i].Listed, p)
+	}
+	return check(ctx, request, managers)
+}
+
+// searchStateQualifiers are the search qualifiers for each of the pull request states.
+var searchStateQualifiers = map[string]string{
+	"OPEN":   "is:open",
+	"CLOSED": "is:closed is:unmerged",
+	"MERGED": "is:merged",
+}
+
+// repositoryManager is the manager for one of the checked repositories. The
+// repository is empty when checking a single repository. Listed holds the pull
+// requests when they were found by a search, and are not listed again.
+type repositoryManager struct {
+	Repository string
+	Listed     []*PullRequest
+	Github
+}
+
+func check(ctx context.Context, request CheckRequest, managers []repositoryManager) (CheckResponse, error) {
+	var response CheckResponse
+
+	request.Source.ApplyDefaults()
+	if err := dumpSource(request.Source); err != nil {
+		return nil, err
+	}
+
+	var states []githubv4.PullRequestState
+	for _, s := range request.Source.States {
+		states = append(states, githubv4.PullRequestState(strings.ToUpper(s)))
+	}
+	var order *githubv4.IssueOrder
+	if o := request.Source.GithubOrderBy; o != nil {
+		order = &githubv4.IssueOrder{
+			Field:     githubv4.IssueOrderField(strings.ToUpper(o.Field)),
+			Direction: githubv4.OrderDirectionAsc,
+		}
+		if o.Direction != "" {
+			order.Direction = githubv4.OrderDirection(strings.ToUpper(o.Direction))
+		}
+	}
+	config, err := request.Source.parse()
+	if err != nil {
+		return nil, err
+	}
+	var pulls []*PullRequest
+	managerOf := make(map[*PullRequest]repositoryManager)
+	for _, manager := range managers {
+		var listed []*PullRequest
+		if config.FailOnArchived {
+			repository, err := manager.GetRepository(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if repository.IsArchived {
+				name := manager.Repository
+				if name == "" {
+					name = request.Source.Repository
+				}
+				return nil, &RepositoryUnavailableError{Repository: name, Archived: true}
+			}
+		}
+		if manager.Listed != nil {
+			listed = manager.Listed
+		} else if config.WebhookOptimized && request.Version.PR != "" {
+			// Only look at the last commit of the PR in the current version.
+			pull, err := manager.GetPullRequest(ctx, request.Version.PR, "")
+			if err != nil {
+				return nil, fmt.Errorf("failed to get pull request: %w", err)
+			}
+			listed = append(listed, pull)
+		} else {
+			listed, err = manager.ListPullRequests(ctx, states, request.Source.MaxCommitsPerPR, order)
+			if _, ok := err.(*RepositoryUnavailableError); ok {
+				return nil, err
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get last commits: %w", err)
+			}
+		}
+		for _, p := range listed {
+			managerOf[p] = manager
+		}
+		pulls = append(pulls, listed...)
+	}
+	// Only consider the listed PRs (e.g. when debugging or backfilling).
+	if len(request.Source.OnlyPRs) > 0 {
+		only := make(map[int]bool)
+		for _, n := range request.Source.OnlyPRs {
+			only[n] = true
+		}
+		var selected []*PullRequest
+		for _, p := range pulls {
+			if only[p.Number] {
+				selected = append(selected, p)
+			}
+		}
+		pulls = selected
+	}
+	// A reopened pull request counts as new from the time it was reopened.
+	date := func(p *PullRequest) time.Time {
+		d := versionDate(p, request.Source.VersionKey)
+		if config.TriggerOnReopen && p.ReopenedAt.Time.After(d) {
+			d = p.ReopenedAt.Time
+		}
+		return d
+	}
+	// Log the filter decision for a PR to stderr (stdout is reserved for the response).
+	logf := func(p *PullRequest, format string, a ...interface{}) {
+		if config.Trace {
+			fmt.Fprintf(os.Stderr, "PR #%d %s\n", p.Number, fmt.Sprintf(format, a...))
+		}
+	}
+	// PRs in different repositories can have the same number.
+	prKey := func(p *PullRequest) string {
+		return managerOf[p].Repository + "#" + strconv.Itoa(p.Number)
+	}
+	// Count skipped PRs by reason for the summary.
+	summary := newCheckSummary(pulls, prKey)
+	skipf := func(p *PullRequest, reason, format string, a ...interface{}) {
+		summary.Skipped[reason]++
+		logf(p, "skipped: "+format, a...)
+	}
+
+	// The newest commit of each PR, for version_strategy sha.
+	lastCommit := make(map[string]time.Time)
+	for _, p := range pulls {
+		if d := p.Tip.CommittedDate.Time; d.After(lastCommit[prKey(p)]) {
+			lastCommit[prKey(p)] = d
+		}
+	}
+
+	// With version_strategy sha, a commit is new unless it is the commit of the current
+	// version: a changed tip of that PR is always new, and commits sharing its timestamp are kept.
+	isNewer := func(p *PullRequest) bool {
+		if request.Source.VersionStrategy != "sha" {
+			return date(p).After(request.Version.CommittedDate)
+		}
+		if p.Tip.OID == request.Version.Commit {
+			return false
+		}
+		current := strconv.Itoa(p.Number) == request.Version.PR && managerOf[p].Repository == request.Version.Repository
+		if current && p.Tip.CommittedDate.Time.Equal(lastCommit[prKey(p)]) {
+			return true
+		}
+		return !date(p).Before(request.Version.CommittedDate)
+	}
+
+	// Whether any commit of a PR contains [ci skip], with skip_ci_scan_all_commits.
+	skipCIInCommits := make(map[string]bool)
+	// The issues linked to a PR, with linked_issue_label.
+	linkedIssues := make(map[string][]LinkedIssue)
+	// The status contexts required for each base branch, with required_status_checks.
+	requiredContexts := make(map[string][]string)
+
+	var candidates []*PullRequest
 	for _, p := range pulls {
 		// [ci skip]/[skip ci] in Pull request title
-		if !disableSkipCI && ContainsSkipCI(p.Title) {
+		if !config.DisableCISkip && ContainsSkipCI(p.Title) {
+			skipf(p, "ci_skip", "title contains [ci skip]")
 			continue
 		}
 		// [ci skip]/[skip ci] in Commit message
-		if !disableSkipCI && ContainsSkipCI(p.Tip.Message) {
+		if !config.DisableCISkip && ContainsSkipCI(p.Tip.Message) {
+			skipf(p, "ci_skip", "commit message contains [ci skip]")
 			continue
 		}
-		// Filter out commits that are too old.
-		if !p.Tip.CommittedDate.Time.After(request.Version.CommittedDate) {
+		// Filter out commits that are too old (a batch covers all matching PRs).
+		if !config.BatchMode && !isNewer(p) {
+			skipf(p, "not_newer", "commit %s is not newer than the current version", p.Tip.OID)
 			continue
 		}
-
-		// Fetch files once if paths/ignore_paths are specified.
-		var files []string
-
-		if len(request.Source.Paths) > 0 || len(request.Source.IgnorePaths) > 0 {
-			files, err = manager.ListModifiedFiles(p.Number)
-			if err != nil {
-				return nil, fmt.Errorf("failed to list modified files: %s", err)
-			}
+		// Filter out PRs before the configured cutoff.
+		if p.Number < request.Source.SincePR {
+			skipf(p, "since", "pull request is older than since_pr")
+			continue
 		}
-
-		// Skip version if no files match the specified paths.
-		if len(request.Source.Paths) > 0 {
-			var wanted []string
-			for _, pattern := range request.Source.Paths {
-				w, err := FilterPath(files, pattern)
+		if p.UpdatedAt.Time.Before(config.SinceDate) {
+			skipf(p, "since", "pull request was last updated before since_date")
+			continue
+		}
+		// Filter out PRs from forks.
+		if config.DisableForks && p.IsCrossRepository {
+			skipf(p, "fork", "pull request is from a fork")
+			continue
+		}
+		// Filter out PRs from authors without a required association (e.g. first-time contributors).
+		if !p.HasAuthorAssociation(request.Source.RequireAssociation) {
+			skipf(p, "author_association", "author association is %s", p.AuthorAssociation)
+			continue
+		}
+		// Filter out PRs in archived repositories.
+		if config.SkipArchived && p.Repository.IsArchived {
+			skipf(p, "archived", "repository is archived")
+			continue
+		}
+		// Filter out PRs that are queued for auto-merge.
+		if config.SkipAutoMerge && p.QueuedForAutoMerge() {
+			skipf(p, "auto_merge", "pull request is queued for auto-merge")
+			continue
+		}
+		// Filter out PRs with too many commits (e.g. to be squashed first).
+		if max := request.Source.MaxCommits; max > 0 && p.CommitCount.TotalCount > max {
+			skipf(p, "max_commits", "pull request has %d commits (more than max_commits)", p.CommitCount.TotalCount)
+			continue
+		}
+		// Filter out PRs whose checks are not (yet) in the required state.
+		if rs := request.Source.RequireStatus; rs != "" && !strings.EqualFold(p.Tip.Status(), rs) {
+			skipf(p, "require_status", "commit %s has status %q", p.Tip.OID, p.Tip.Status())
+			continue
+		}
+		// Filter out PRs with an ignored label.
+		if p.HasAnyLabel(request.Source.IgnoreLabels) {
+			skipf(p, "ignore_labels", "pull request has an ignored label")
+			continue
+		}
+		// Filter out PRs whose title does not match.
+		if config.TitleRegex != nil && !config.TitleRegex.MatchString(p.Title) {
+			skipf(p, "title_regex", "title does not match title_regex")
+			continue
+		}
+		// Filter out PRs that are not attached to the milestone.
+		if m := request.Source.Milestone; m != "" && !p.HasMilestone(m) {
+			skipf(p, "milestone", "pull request is not in milestone %s", m)
+			continue
+		}
+		// Filter out commits that are too fresh, they are picked up by a later check.
+		if config.MinCommitAge > 0 && p.Tip.CommittedDate.Time.After(now().Add(-config.MinCommitAge)) {
+			skipf(p, "min_commit_age", "commit %s is younger than min_commit_age", p.Tip.OID)
+			continue
+		}
+		// Filter out all commits of PRs that were updated (e.g. pushed to) during the quiet period,
+		// which unlike min_commit_age also covers pushes of commits with older timestamps.
+		if config.QuietPeriod > 0 && p.UpdatedAt.Time.After(now().Add(-config.QuietPeriod)) {
+			skipf(p, "quiet_period", "pull request was updated within quiet_period")
+			continue
+		}
+		// [ci skip]/[skip ci] in any commit message of the PR (listed once per PR, after the cheaper filters)
+		if !config.DisableCISkip && config.SkipCIScanAllCommits {
+			skip, ok := skipCIInCommits[prKey(p)]
+			if !ok {
+				messages, err := managerOf[p].ListCommitMessages(ctx, p.Number)
 				if err != nil {
-					return nil, fmt.Errorf("path match failed: %s", err)
+					return nil, fmt.Errorf("failed to list commit messages: %w", err)
 				}
-				wanted = append(wanted, w...)
+				for _, m := range messages {
+					skip = skip || ContainsSkipCI(m)
+				}
+				skipCIInCommits[prKey(p)] = skip
 			}
-			if len(wanted) == 0 {
-				continue Loop
+			if skip {
+				skipf(p, "ci_skip", "a commit message of the pull request contains [ci skip]")
+				continue
 			}
 		}
-
-		// Skip version if all files are ignored.
-		if len(request.Source.IgnorePaths) > 0 {
-			wanted := files
-			for _, pattern := range request.Source.IgnorePaths {
-				wanted, err = FilterIgnorePath(wanted, pattern)
-				if err != nil {
-					return nil, fmt.Errorf("ignore path match failed: %s", err)
+		// Filter out PRs without a linked issue that has the label (listed once per PR, after the cheaper filters).
+		if l := request.Source.LinkedIssueLabel; l != "" {
+			issues, ok := linkedIssues[prKey(p)]
+			if !ok {
+				var err error
+				if issues, err = managerOf[p].ListLinkedIssues(ctx, p.Number); err != nil {
+					return nil, fmt.Errorf("failed to list linked issues: %w", err)
 				}
+				linkedIssues[prKey(p)] = issues
 			}
-			if len(wanted) == 0 {
-				continue Loop
+			p.LinkedIssues = issues
+			if !p.HasLinkedIssueLabel(l) {
+				skipf(p, "linked_issue_label", "no linked issue labeled %s", l)
+				continue
 			}
 		}
-		response = append(response, NewVersion(p))
+		// Filter out commits where a required status check has not passed (after the cheaper filters).
+		if len(request.Source.RequiredStatusChecks) > 0 {
+			key := managerOf[p].Repository + ":" + p.BaseRefName
+			required, ok := requiredContexts[key]
+			if !ok {
+				var err error
+				if required, err = requiredStatusContexts(ctx, managerOf[p], request.Source.RequiredStatusChecks, p.BaseRefName); err != nil {
+					return nil, fmt.Errorf("failed to get required status checks: %w", err)
+				}
+				requiredContexts[key] = required
+			}
+			if len(required) > 0 {
+				states, err := managerOf[p].GetStatusContexts(ctx, p.Tip.OID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get status contexts: %w", err)
+				}
+				if c, ok := failedStatusContext(required, states); ok {
+					skipf(p, "required_status_checks", "commit %s has not passed required status check %s", p.Tip.OID, c)
+					continue
+				}
+			}
+		}
+		candidates = append(candidates, p)
 	}
 
-	// Sort the commits by date
-	sort.Sort(response)
+	// Listing modified files is the slowest part of a check, so the
+	// paths/ignore_paths/min_changed_files filters are evaluated for all candidates concurrently.
+	// They can be skipped on the first check (without a current version).
+	reasons := make([]string, len(candidates))
+	firstRun := request.Version.PR == "" && request.Version.Batch == ""
+	if (len(request.Source.Paths) > 0 || len(request.Source.IgnorePaths) > 0 || request.Source.MinChangedFiles > 0) && !(config.PathsSkipOnFirstRun && firstRun) {
+		err := forEachConcurrently(len(candidates), request.Source.Concurrency, func(i int) error {
+			reason, err := filterModifiedFiles(ctx, managerOf[candidates[i]], candidates[i], request.Source, config.RespectExportIgnore)
+			reasons[i] = reason
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, p := range candidates {
+		if reasons[i] != "" {
+			skipf(p, "paths", "%s", reasons[i])
+			continue
+		}
+		logf(p, "kept: commit %s", p.Tip.OID)
+		version := NewVersion(p)
+		version.CommittedDate = date(p)
+		version.Repository = managerOf[p].Repository
+		response = append(response, version)
+	}
+
+	// Sort the commits by date (stable, so ties keep the order they were listed in)
+	sort.Stable(response)
+
+	if config.BatchMode {
+		response = checkBatch(request.Version, limitCommitsPerPR(response, 1), request.Source.MaxTrackedPRs)
+	} else {
+		// Only keep the newest commits for each PR
+		response = limitCommitsPerPR(response, request.Source.MaxCommitsPerPR)
 
-	// If there are no new but an old version = return the old
-	if len(response) == 0 && request.Version.PR != "" {
-		response = append(response, request.Version)
+		// If there are new versions and no previous = return just the latest (unless backfilling),
+		// which is picked by date before any other ordering
+		if len(response) != 0 && request.Version.PR == "" && !config.BackfillOnFirstRun {
+			response = CheckResponse{response[len(response)-1]}
+		}
+
+		// Order by PR number instead if configured (commits of a PR stay ordered by date)
+		if request.Source.CheckSortKey == "pr" {
+			sort.SliceStable(response, func(i, j int) bool {
+				a, _ := strconv.Atoi(response[i].PR)
+				b, _ := strconv.Atoi(response[j].PR)
+				return a < b
+			})
+		}
+
+		// If there are no new but an old version = return the old
+		if len(response) == 0 && request.Version.PR != "" {
+			response = append(response, request.Version)
+		}
 	}
-	// If there are new versions and no previous = return just the latest
-	if len(response) != 0 && request.Version.PR == "" {
-		response = CheckResponse{response[len(response)-1]}
+
+	summary.Versions = len(response)
+	if err := writeCheckSummary(summary); err != nil {
+		return nil, err
 	}
 	return response, nil
 }
 
+// checkSummary counts the pull requests seen by a check, how many were skipped
+// (by reason) and the number of versions returned.
+type checkSummary struct {
+	PullRequests int            `json:"pull_requests"`
+	Skipped      map[string]int `json:"skipped"`
+	Versions     int            `json:"versions"`
+}
+
+func newCheckSummary(pulls []*PullRequest, key func(*PullRequest) string) *checkSummary {
+	keys := make(map[string]bool)
+	for _, p := range pulls {
+		keys[key(p)] = true
+	}
+	return &checkSummary{PullRequests: len(keys), Skipped: make(map[string]int)}
+}
+
+// writeCheckSummary writes the summary as a single line of JSON to stderr when
+// GITHUB_PR_CHECK_SUMMARY is true.
+func writeCheckSummary(summary *checkSummary) error {
+	if enabled, _ := strconv.ParseBool(os.Getenv("GITHUB_PR_CHECK_SUMMARY")); !enabled {
+		return nil
+	}
+	b, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal check summary: %w", err)
+	}
+	_, err = fmt.Fprintf(os.Stderr, "%s\n", b)
+	return err
+}
+
+// checkBatch returns a single version covering the newest commit of every
+// matching PR, or the previous version if the set of PRs is unchanged. PRs that
+// no longer match are dropped, and only the (at most) max most recent PRs are
+// kept if max is set. The response must be sorted by date.
+func checkBatch(previous Version, response CheckResponse, max int) CheckResponse {
+	if max > 0 && len(response) > max {
+		response = response[len(response)-max:]
+	}
+	if len(response) == 0 {
+		if previous.Batch != "" {
+			return CheckResponse{previous}
+		}
+		return nil
+	}
+	batch := NewBatchVersion(response)
+	if batch.Batch == previous.Batch {
+		return CheckResponse{previous}
+	}
+	return CheckResponse{batch}
+}
+
+// limitCommitsPerPR drops all but the newest max versions of each PR from a sorted response.
+func limitCommitsPerPR(response CheckResponse, max int) CheckResponse {
+	count := make(map[string]int)
+	keep := make([]bool, len(response))
+	for i := len(response) - 1; i >= 0; i-- {
+		key := response[i].Repository + "#" + response[i].PR
+		count[key]++
+		keep[i] = count[key] <= max
+	}
+	var out CheckResponse
+	for i, v := range response {
+		if keep[i] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// dumpSource writes the source (with defaults applied and secrets redacted) for
+// debugging when GITHUB_PR_DUMP_SOURCE is set. A boolean true writes to stderr,
+// any other value (that is not a boolean) is used as the path of the file to write.
+func dumpSource(source Source) error {
+	dump := os.Getenv("GITHUB_PR_DUMP_SOURCE")
+	if dump == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(source.Redacted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal source: %w", err)
+	}
+	b = append(b, '\n')
+
+	if enabled, err := strconv.ParseBool(dump); err == nil {
+		if enabled {
+			_, err = os.Stderr.Write(b)
+		}
+		return err
+	}
+	if err := ioutil.WriteFile(dump, b, 0644); err != nil {
+		return fmt.Errorf("failed to write source: %w", err)
+	}
+	return nil
+}
+
 // ContainsSkipCI returns true if a string contains [ci skip] or [skip ci].
 func ContainsSkipCI(s string) bool {
 	re := regexp.MustCompile("(?i)\\[(ci skip|skip ci)\\]")
 	return re.MatchString(s)
 }
 
+// requiredStatusContexts returns the explicit contexts of required_status_checks,
+// and (for auto) the contexts required by branch protection of the base branch.
+func requiredStatusContexts(ctx context.Context, manager Github, checks []string, baseRef string) ([]string, error) {
+	var required []string
+	seen := make(map[string]bool)
+	add := func(contexts ...string) {
+		for _, c := range contexts {
+			if !seen[c] {
+				seen[c] = true
+				required = append(required, c)
+			}
+		}
+	}
+	for _, c := range checks {
+		if c != "auto" {
+			add(c)
+			continue
+		}
+		protected, err := manager.GetRequiredStatusContexts(ctx, baseRef)
+		if err != nil {
+			return nil, err
+		}
+		add(protected...)
+	}
+	return required, nil
+}
+
+// failedStatusContext returns the first of the required contexts that has not
+// passed (is missing, pending or failed) on the commit.
+func failedStatusContext(required []string, states map[string]string) (string, bool) {
+	for _, c := range required {
+		switch strings.ToUpper(states[c]) {
+		case "SUCCESS", "NEUTRAL", "SKIPPED":
+		default:
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// DefaultConcurrency is the number of pull requests for which modified files
+// are listed in parallel, unless configured otherwise.
+const DefaultConcurrency = 4
+
+// filterModifiedFiles applies the paths and ignore_paths filters to the files
+// modified in a pull request. It returns the reason the pull request should be
+// skipped, or an empty string if it should be kept.
+func filterModifiedFiles(ctx context.Context, manager Github, p *PullRequest, source Source, exportIgnore bool) (string, error) {
+	// Fetch all files once if ignore_paths (or min_changed_files) are specified, or
+	// export-ignore is respected. Otherwise paths are matched one page at a time,
+	// stopping at the first match.
+	var files []string
+	fetchAll := len(source.IgnorePaths) > 0 || source.MinChangedFiles > 0 || exportIgnore
+	if fetchAll {
+		var err error
+		files, err = manager.ListModifiedFiles(ctx, p.Number)
+		if err != nil {
+			return "", fmt.Errorf("failed to list modified files: %w", err)
+		}
+	}
+
+	// Files marked export-ignore in .gitattributes (e.g. generated files) do not count.
+	if exportIgnore {
+		attributes, err := manager.GetFileContent(ctx, ".gitattributes", p.Tip.OID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get .gitattributes: %w", err)
+		}
+		files = filterExportIgnore(files, exportIgnorePatterns(attributes))
+	}
+
+	// Skip version if no files match the specified paths.
+	if len(source.Paths) > 0 {
+		var match bool
+		var err error
+		if fetchAll {
+			match, err = matchPaths(files, source.Paths)
+		} else {
+			match, err = hasModifiedPath(ctx, manager, p.Number, source.Paths)
+		}
+		if err != nil {
+			return "", err
+		}
+		if !match {
+			return "no files match paths", nil
+		}
+	}
+
+	// Skip version if all files are ignored.
+	if len(source.IgnorePaths) > 0 {
+		wanted, err := filterIgnorePaths(files, source.IgnorePaths)
+		if err != nil {
+			return "", err
+		}
+		if len(wanted) == 0 {
+			return "all files match ignore_paths", nil
+		}
+	}
+
+	// Skip version if too few files match the paths (and are not ignored).
+	if source.MinChangedFiles > 0 {
+		n, err := countMatchingFiles(files, source.Paths, source.IgnorePaths)
+		if err != nil {
+			return "", err
+		}
+		if n < source.MinChangedFiles {
+			return fmt.Sprintf("%d of %d required files match paths", n, source.MinChangedFiles), nil
+		}
+	}
+	return "", nil
+}
+
+// countMatchingFiles counts the files that match any of the paths (all files
+// match if there are none), and none of the ignore paths.
+func countMatchingFiles(files, paths, ignorePaths []string) (int, error) {
+	var n int
+	for _, file := range files {
+		wanted := []string{file}
+		if len(paths) > 0 {
+			match, err := matchPaths(wanted, paths)
+			if err != nil {
+				return 0, err
+			}
+			if !match {
+				continue
+			}
+		}
+		wanted, err := filterIgnorePaths(wanted, ignorePaths)
+		if err != nil {
+			return 0, err
+		}
+		n += len(wanted)
+	}
+	return n, nil
+}
+
+// exportIgnorePatterns returns the patterns that have the export-ignore
+// attribute set in the content of a .gitattributes file.
+func exportIgnorePatterns(attributes string) []string {
+	var patterns []string
+	for _, line := range strings.Split(attributes, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			if attr == "export-ignore" {
+				patterns = append(patterns, fields[0])
+			}
+		}
+	}
+	return patterns
+}
+
+// filterExportIgnore removes the files matching any of the export-ignore
+// patterns. As in .gitattributes, a pattern without a slash matches the name of
+// a file in any directory, and a pattern ending in /** matches everything below it.
+func filterExportIgnore(files []string, patterns []string) []string {
+	var out []string
+	for _, file := range files {
+		name := normalizePath(file)
+		ignored := false
+		for _, pattern := range patterns {
+			var match bool
+			switch {
+			case strings.HasSuffix(pattern, "/**"):
+				match = strings.HasPrefix(name, strings.TrimPrefix(strings.TrimSuffix(pattern, "**"), "/"))
+			case !strings.Contains(pattern, "/"):
+				match, _ = filepath.Match(pattern, filepath.Base(name))
+			default:
+				match, _ = filepath.Match(strings.TrimPrefix(pattern, "/"), name)
+			}
+			if match {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			out = append(out, file)
+		}
+	}
+	return out
+}
+
+// forEachConcurrently calls fn for every index in [0, n) using at most
+// concurrency goroutines. No new calls are started after the first error,
+// which is returned once all running calls have finished.
+func forEachConcurrently(n, concurrency int, fn func(int) error) error {
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	jobs := make(chan int)
+	done := make(chan struct{})
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(i); err != nil {
+					once.Do(func() {
+						first = err
+						close(done)
+					})
+				}
+			}
+		}()
+	}
+
+Loop:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-done:
+			break Loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return first
+}
+
+// hasModifiedPath pages through the files modified in a pull request and
+// returns as soon as one of them matches the patterns.
+func hasModifiedPath(ctx context.Context, manager Github, prNumber int, patterns []string) (bool, error) {
+	for page := 1; page != 0; {
+		files, next, err := manager.ListModifiedFilesPage(ctx, prNumber, page)
+		if err != nil {
+			return false, fmt.Errorf("failed to list modified files: %w", err)
+		}
+		match, err := matchPaths(files, patterns)
+		if err != nil || match {
+			return match, err
+		}
+		page = next
+	}
+	return false, nil
+}
+
+// MatchesPaths returns true if the files of a pull request pass the paths and
+// ignore_paths filters of check: any of the files match one of the paths (if
+// there are any), and not all of the files match the ignore paths.
+func MatchesPaths(files, paths, ignorePaths []string) (bool, error) {
+	if len(paths) > 0 {
+		match, err := matchPaths(files, paths)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	if len(ignorePaths) > 0 {
+		wanted, err := filterIgnorePaths(files, ignorePaths)
+		if err != nil || len(wanted) == 0 {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// filterIgnorePaths removes the files that match any of the ignore paths.
+func filterIgnorePaths(files, ignorePaths []string) ([]string, error) {
+	wanted := files
+	for _, pattern := range ignorePaths {
+		var err error
+		wanted, err = FilterIgnorePath(wanted, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("ignore path match failed: %w", err)
+		}
+	}
+	return wanted, nil
+}
+
+// matchPaths returns true if any of the files match one of the patterns.
+func matchPaths(files []string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		w, err := FilterPath(files, pattern)
+		if err != nil {
+			return false, fmt.Errorf("path match failed: %w", err)
+		}
+		if len(w) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// normalizePath replaces backslash separators (from commits made on Windows)
+// with forward slashes, so patterns match the same files on all platforms.
+func normalizePath(file string) string {
+	return strings.Replace(file, `\`, "/", -1)
+}
+
 // FilterIgnorePath ...
 func FilterIgnorePath(files []string, pattern string) ([]string, error) {
 	var out []string
 	for _, file := range files {
-		match, err := filepath.Match(pattern, file)
+		match, err := filepath.Match(pattern, normalizePath(file))
 		if err != nil {
 			return nil, err
 		}
@@ -119,7 +873,7 @@ func FilterIgnorePath(files []string, pattern string) ([]string, error) {
 func FilterPath(files []string, pattern string) ([]string, error) {
 	var out []string
 	for _, file := range files {
-		match, err := filepath.Match(pattern, file)
+		match, err := filepath.Match(pattern, normalizePath(file))
 		if err != nil {
 			return nil, err
 		}
@@ -1,12 +1,23 @@
 package resource_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/github"
 	"github.com/itsdalmo/github-pr-resource"
 	"github.com/itsdalmo/github-pr-resource/mocks"
+	"github.com/shurcooL/githubv4"
 )
 
 var (
@@ -16,6 +27,7 @@ var (
 		createTestPR(3, false),
 		createTestPR(4, false),
 	}
+	openStates = []githubv4.PullRequestState{githubv4.PullRequestStateOpen}
 )
 
 func TestCheck(t *testing.T) {
@@ -23,7 +35,8 @@ func TestCheck(t *testing.T) {
 		description  string
 		source       resource.Source
 		version      resource.Version
-		files        [][]string
+		files        map[int][]string
+		pages        map[int][][]string
 		pullRequests []*resource.PullRequest
 		expected     resource.CheckResponse
 	}{
@@ -35,7 +48,52 @@ func TestCheck(t *testing.T) {
 			},
 			version:      resource.Version{},
 			pullRequests: testPullRequests,
-			files:        [][]string{},
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+
+		{
+			description: "check returns all versions if there is no previous when backfilling",
+			source: resource.Source{
+				Repository:         "itsdalmo/test-repository",
+				AccessToken:        "oauthtoken",
+				BackfillOnFirstRun: "true",
+			},
+			version:      resource.Version{},
+			pullRequests: testPullRequests,
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[3]),
+				resource.NewVersion(testPullRequests[2]),
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+
+		{
+			description: "check only considers the pull requests in only_prs",
+			source: resource.Source{
+				Repository:         "itsdalmo/test-repository",
+				AccessToken:        "oauthtoken",
+				BackfillOnFirstRun: "true",
+				OnlyPRs:            []int{2, 4},
+			},
+			version:      resource.Version{},
+			pullRequests: testPullRequests,
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[3]),
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+
+		{
+			description: "check only returns new versions of the pull requests in only_prs",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				OnlyPRs:     []int{2, 4},
+			},
+			version:      resource.NewVersion(testPullRequests[3]),
+			pullRequests: testPullRequests,
 			expected: resource.CheckResponse{
 				resource.NewVersion(testPullRequests[1]),
 			},
@@ -49,7 +107,6 @@ func TestCheck(t *testing.T) {
 			},
 			version:      resource.NewVersion(testPullRequests[1]),
 			pullRequests: testPullRequests,
-			files:        [][]string{},
 			expected: resource.CheckResponse{
 				resource.NewVersion(testPullRequests[1]),
 			},
@@ -63,7 +120,6 @@ func TestCheck(t *testing.T) {
 			},
 			version:      resource.NewVersion(testPullRequests[3]),
 			pullRequests: testPullRequests,
-			files:        [][]string{},
 			expected: resource.CheckResponse{
 				resource.NewVersion(testPullRequests[2]),
 				resource.NewVersion(testPullRequests[1]),
@@ -79,10 +135,46 @@ func TestCheck(t *testing.T) {
 			},
 			version:      resource.NewVersion(testPullRequests[3]),
 			pullRequests: testPullRequests,
-			files: [][]string{
-				{"README.md", "travis.yml"},
-				{"terraform/modules/ecs/main.tf", "README.md"},
-				{"terraform/modules/variables.tf", "travis.yml"},
+			files: map[int][]string{
+				2: {"README.md", "travis.yml"},
+				3: {"terraform/modules/ecs/main.tf", "README.md"},
+			},
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[2]),
+			},
+		},
+
+		{
+			description: "check will only return versions with enough files matching the paths",
+			source: resource.Source{
+				Repository:      "itsdalmo/test-repository",
+				AccessToken:     "oauthtoken",
+				Paths:           []string{"terraform/*/*.tf", "terraform/*/*/*.tf"},
+				MinChangedFiles: 2,
+			},
+			version:      resource.NewVersion(testPullRequests[3]),
+			pullRequests: testPullRequests,
+			files: map[int][]string{
+				2: {"terraform/modules/ecs/main.tf", "terraform/modules/ecs/variables.tf", "README.md"},
+				3: {"terraform/modules/ecs/main.tf", "README.md"},
+			},
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+
+		{
+			description: "check will match paths against all pages of modified files",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				Paths:       []string{"terraform/*/*.tf"},
+			},
+			version:      resource.NewVersion(testPullRequests[3]),
+			pullRequests: testPullRequests,
+			pages: map[int][][]string{
+				2: {{"README.md", "travis.yml"}, {"docs/index.md"}},
+				3: {{"README.md", "travis.yml"}, {"terraform/ecs/main.tf"}},
 			},
 			expected: resource.CheckResponse{
 				resource.NewVersion(testPullRequests[2]),
@@ -98,10 +190,9 @@ func TestCheck(t *testing.T) {
 			},
 			version:      resource.NewVersion(testPullRequests[3]),
 			pullRequests: testPullRequests,
-			files: [][]string{
-				{"README.md", "travis.yml"},
-				{"terraform/modules/ecs/main.tf", "README.md"},
-				{"terraform/modules/variables.tf", "travis.yml"},
+			files: map[int][]string{
+				2: {"README.md", "travis.yml"},
+				3: {"terraform/modules/ecs/main.tf", "README.md"},
 			},
 			expected: resource.CheckResponse{
 				resource.NewVersion(testPullRequests[2]),
@@ -128,14 +219,23 @@ func TestCheck(t *testing.T) {
 			defer ctrl.Finish()
 
 			github := mocks.NewMockGithub(ctrl)
-			github.EXPECT().ListOpenPullRequests().Times(1).Return(tc.pullRequests, nil)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(tc.pullRequests, nil)
 
-			if len(tc.files) > 0 {
-				// TODO: Figure out how to do this in a loop with variables. As is, it will break when adding new tests.
-				gomock.InOrder(
-					github.EXPECT().ListModifiedFiles(gomock.Any()).Times(1).Return(tc.files[0], nil),
-					github.EXPECT().ListModifiedFiles(gomock.Any()).Times(1).Return(tc.files[1], nil),
-				)
+			for number, files := range tc.files {
+				if len(tc.source.IgnorePaths) > 0 || tc.source.MinChangedFiles > 0 {
+					github.EXPECT().ListModifiedFiles(gomock.Any(), number).Times(1).Return(files, nil)
+				} else {
+					github.EXPECT().ListModifiedFilesPage(gomock.Any(), number, 1).Times(1).Return(files, 0, nil)
+				}
+			}
+			for number, pages := range tc.pages {
+				for i, files := range pages {
+					next := i + 2
+					if next > len(pages) {
+						next = 0
+					}
+					github.EXPECT().ListModifiedFilesPage(gomock.Any(), number, i+1).Times(1).Return(files, next, nil)
+				}
 			}
 
 			input := resource.CheckRequest{Source: tc.source, Version: tc.version}
@@ -151,162 +251,2419 @@ func TestCheck(t *testing.T) {
 	}
 }
 
-func TestContainsSkipCI(t *testing.T) {
+func TestCheckDumpSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(nil, nil)
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "source.json")
+
+	os.Setenv("GITHUB_PR_DUMP_SOURCE", path)
+	defer os.Unsetenv("GITHUB_PR_DUMP_SOURCE")
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+		},
+	}
+	if _, err := resource.Check(input, github); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dump := readTestFile(t, path)
+	for _, want := range []string{`"access_token": "REDACTED"`, `"states": [`, `"version_key": "committed"`} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("expected dump to contain %s, got:\n%s", want, dump)
+		}
+	}
+}
+
+func TestCheckStates(t *testing.T) {
 	tests := []struct {
 		description string
-		message     string
-		want        bool
+		states      []string
+		expected    []githubv4.PullRequestState
 	}{
 		{
-			description: "does not just match any symbol in the regexp",
-			message:     "(",
-			want:        false,
-		},
-		{
-			description: "does not match when it should not",
-			message:     "test",
-			want:        false,
-		},
-		{
-			description: "matches [ci skip]",
-			message:     "[ci skip]",
-			want:        true,
-		},
-		{
-			description: "matches [skip ci]",
-			message:     "[skip ci]",
-			want:        true,
-		},
-		{
-			description: "matches trailing skip ci",
-			message:     "trailing [skip ci]",
-			want:        true,
+			description: "check lists open pull requests by default",
+			states:      nil,
+			expected:    []githubv4.PullRequestState{githubv4.PullRequestStateOpen},
 		},
 		{
-			description: "matches leading skip ci",
-			message:     "[skip ci] leading",
-			want:        true,
+			description: "check lists merged pull requests",
+			states:      []string{"MERGED"},
+			expected:    []githubv4.PullRequestState{githubv4.PullRequestStateMerged},
 		},
 		{
-			description: "is case insensitive",
-			message:     "case[Skip CI]insensitive",
-			want:        true,
+			description: "check lists a mix of states",
+			states:      []string{"open", "CLOSED", "merged"},
+			expected: []githubv4.PullRequestState{
+				githubv4.PullRequestStateOpen,
+				githubv4.PullRequestStateClosed,
+				githubv4.PullRequestStateMerged,
+			},
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.description, func(t *testing.T) {
-			got := resource.ContainsSkipCI(tc.message)
-			if got != tc.want {
-				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), tc.expected, 1, nil).Times(1).Return(testPullRequests, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:  "itsdalmo/test-repository",
+					AccessToken: "oauthtoken",
+					States:      tc.states,
+				},
+			}
+			if err := input.Source.Validate(); err != nil {
+				t.Fatalf("unexpected validation error: %s", err)
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, (resource.CheckResponse{resource.NewVersion(testPullRequests[1])}); !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
 			}
 		})
 	}
 }
 
-func TestFilterPath(t *testing.T) {
-	cases := []struct {
+func TestCheckMaxCommitsPerPR(t *testing.T) {
+	// PR #2 gained 4 commits since the last version, while #3 only has one.
+	var pullRequests []*resource.PullRequest
+	for i := 4; i > 0; i-- {
+		p := createTestPR(2, false)
+		p.Tip.OID = fmt.Sprintf("oid2-%d", i)
+		p.Tip.CommittedDate = githubv4.DateTime{Time: time.Now().Add(-time.Duration(i) * time.Hour)}
+		pullRequests = append(pullRequests, p)
+	}
+	pullRequests = append(pullRequests, createTestPR(3, false))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 3, nil).Times(1).Return(pullRequests, nil)
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:      "itsdalmo/test-repository",
+			AccessToken:     "oauthtoken",
+			MaxCommitsPerPR: 3,
+		},
+		Version: resource.NewVersion(testPullRequests[3]),
+	}
+	output, err := resource.Check(input, github)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := resource.CheckResponse{
+		resource.NewVersion(pullRequests[4]),
+		resource.NewVersion(pullRequests[1]),
+		resource.NewVersion(pullRequests[2]),
+		resource.NewVersion(pullRequests[3]),
+	}
+	if got, want := output, expected; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestCheckSortKey(t *testing.T) {
+	// The most recently active PR has the lowest number.
+	var pullRequests []*resource.PullRequest
+	for _, pr := range []struct{ number, hoursAgo int }{{3, 1}, {7, 3}, {5, 2}} {
+		p := createTestPR(pr.number, false)
+		p.Tip.CommittedDate = githubv4.DateTime{Time: time.Now().Add(-time.Duration(pr.hoursAgo) * time.Hour)}
+		pullRequests = append(pullRequests, p)
+	}
+
+	tests := []struct {
 		description string
-		pattern     string
-		files       []string
-		want        []string
+		sortKey     string
+		firstRun    bool
+		expected    []string
 	}{
 		{
-			description: "returns all matching files",
-			pattern:     "*.txt",
-			files: []string{
-				"file1.txt",
-				"test/file2.txt",
-			},
-			want: []string{
-				"file1.txt",
-			},
+			description: "sorts by date by default",
+			sortKey:     "",
+			expected:    []string{"7", "5", "3"},
 		},
 		{
-			description: "works with wildcard",
-			pattern:     "test/*",
-			files: []string{
-				"file1.txt",
-				"test/file2.txt",
-			},
-			want: []string{
-				"test/file2.txt",
-			},
+			description: "sorts by date",
+			sortKey:     "date",
+			expected:    []string{"7", "5", "3"},
 		},
 		{
-			description: "excludes unmatched files",
-			pattern:     "*/*.txt",
-			files: []string{
-				"test/file1.go",
-				"test/file2.txt",
-			},
-			want: []string{
-				"test/file2.txt",
-			},
+			description: "sorts by pr number",
+			sortKey:     "pr",
+			expected:    []string{"3", "5", "7"},
+		},
+		{
+			description: "emits the newest rather than the highest numbered pr on the first run",
+			sortKey:     "pr",
+			firstRun:    true,
+			expected:    []string{"3"},
 		},
 	}
-	for _, tc := range cases {
+
+	for _, tc := range tests {
 		t.Run(tc.description, func(t *testing.T) {
-			got, err := resource.FilterPath(tc.files, tc.pattern)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(pullRequests, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:   "itsdalmo/test-repository",
+					AccessToken:  "oauthtoken",
+					CheckSortKey: tc.sortKey,
+				},
+				Version: resource.NewVersion(createTestPR(10, false)),
+			}
+			if tc.firstRun {
+				input.Version = resource.Version{}
+			}
+			if err := input.Source.Validate(); err != nil {
+				t.Fatalf("invalid source: %s", err)
+			}
+			output, err := resource.Check(input, github)
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
-			if !reflect.DeepEqual(got, tc.want) {
-				t.Errorf("\ngot:\n%v\nwant:\n%s\n", got, tc.want)
+			var got []string
+			for _, v := range output {
+				got = append(got, v.PR)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.expected)
 			}
 		})
 	}
 }
 
-func TestFilterIgnorePath(t *testing.T) {
-	cases := []struct {
+func TestCheckMinCommitAge(t *testing.T) {
+	clock := time.Date(2018, time.May, 14, 12, 0, 0, 0, time.UTC)
+	defer resource.SetNow(func() time.Time { return clock })()
+
+	fresh := createTestPR(2, false)
+	fresh.Tip.CommittedDate = githubv4.DateTime{Time: clock.Add(-1 * time.Minute)}
+	stable := createTestPR(3, false)
+	stable.Tip.CommittedDate = githubv4.DateTime{Time: clock.Add(-5 * time.Minute)}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{fresh, stable}, nil)
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:   "itsdalmo/test-repository",
+			AccessToken:  "oauthtoken",
+			MinCommitAge: "2m",
+		},
+		Version: resource.Version{PR: "1", Commit: "oid1", CommittedDate: clock.Add(-time.Hour)},
+	}
+	output, err := resource.Check(input, github)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := output, (resource.CheckResponse{resource.NewVersion(stable)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestCheckQuietPeriod(t *testing.T) {
+	clock := time.Date(2018, time.May, 14, 12, 0, 0, 0, time.UTC)
+
+	first := createTestPR(2, false)
+	first.Tip.CommittedDate = githubv4.DateTime{Time: clock.Add(-30 * time.Minute)}
+	first.UpdatedAt = githubv4.DateTime{Time: clock.Add(-1 * time.Minute)}
+	pushed := createTestPR(2, false)
+	pushed.Tip.OID = "oid2-pushed"
+	pushed.Tip.CommittedDate = githubv4.DateTime{Time: clock.Add(-1 * time.Minute)}
+	pushed.UpdatedAt = first.UpdatedAt
+	quiet := createTestPR(3, false)
+	quiet.Tip.CommittedDate = githubv4.DateTime{Time: clock.Add(-20 * time.Minute)}
+	quiet.UpdatedAt = quiet.Tip.CommittedDate
+	// An older commit that was only just pushed.
+	rebased := createTestPR(4, false)
+	rebased.Tip.CommittedDate = githubv4.DateTime{Time: clock.Add(-40 * time.Minute)}
+	rebased.UpdatedAt = githubv4.DateTime{Time: clock.Add(-2 * time.Minute)}
+
+	tests := []struct {
 		description string
-		pattern     string
-		files       []string
-		want        []string
+		now         time.Time
+		expected    resource.CheckResponse
 	}{
 		{
-			description: "excludes all matching files",
-			pattern:     "*.txt",
-			files: []string{
-				"file1.txt",
-				"test/file2.txt",
-			},
-			want: []string{
-				"test/file2.txt",
-			},
-		},
-		{
-			description: "works with wildcard",
-			pattern:     "test/*",
-			files: []string{
-				"file1.txt",
-				"test/file2.txt",
-			},
-			want: []string{
-				"file1.txt",
+			description: "suppresses all commits of a pull request updated during the quiet period",
+			now:         clock,
+			expected: resource.CheckResponse{
+				resource.NewVersion(quiet),
 			},
 		},
 		{
-			description: "includes unmatched files",
-			pattern:     "*/*.txt",
-			files: []string{
-				"test/file1.go",
-				"test/file2.txt",
-			},
-			want: []string{
-				"test/file1.go",
+			description: "releases the commits once the pull request has been quiet",
+			now:         clock.Add(15 * time.Minute),
+			expected: resource.CheckResponse{
+				resource.NewVersion(rebased),
+				resource.NewVersion(first),
+				resource.NewVersion(quiet),
+				resource.NewVersion(pushed),
 			},
 		},
 	}
-	for _, tc := range cases {
+
+	for _, tc := range tests {
 		t.Run(tc.description, func(t *testing.T) {
-			got, err := resource.FilterIgnorePath(tc.files, tc.pattern)
+			defer resource.SetNow(func() time.Time { return tc.now })()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 2, nil).Times(1).Return([]*resource.PullRequest{first, pushed, quiet, rebased}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:      "itsdalmo/test-repository",
+					AccessToken:     "oauthtoken",
+					MaxCommitsPerPR: 2,
+					QuietPeriod:     "10m",
+				},
+				Version: resource.Version{PR: "1", Commit: "oid1", CommittedDate: clock.Add(-time.Hour)},
+			}
+			output, err := resource.Check(input, github)
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
-			if !reflect.DeepEqual(got, tc.want) {
-				t.Errorf("\ngot:\n%v\nwant:\n%s\n", got, tc.want)
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckLinkedIssueLabel(t *testing.T) {
+	unlinked := createTestPR(2, false)
+	unlabeled := createTestPR(3, false)
+	labeled := createTestPR(4, false)
+	// Filtered out before the linked issues are listed.
+	ignored := createTestPR(6, false)
+	ignored.Labels = []string{"wip"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{unlinked, unlabeled, labeled, ignored}, nil)
+	github.EXPECT().ListLinkedIssues(gomock.Any(), 2).Times(1).Return(nil, nil)
+	github.EXPECT().ListLinkedIssues(gomock.Any(), 3).Times(1).Return([]resource.LinkedIssue{
+		{Number: 10, Labels: []string{"bug"}},
+	}, nil)
+	github.EXPECT().ListLinkedIssues(gomock.Any(), 4).Times(1).Return([]resource.LinkedIssue{
+		{Number: 11},
+		{Number: 12, Labels: []string{"bug", "Priority:High"}},
+	}, nil)
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:       "itsdalmo/test-repository",
+			AccessToken:      "oauthtoken",
+			LinkedIssueLabel: "priority:high",
+			IgnoreLabels:     []string{"wip"},
+		},
+		Version: resource.NewVersion(createTestPR(5, false)),
+	}
+	output, err := resource.Check(input, github)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := output, (resource.CheckResponse{resource.NewVersion(labeled)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestCheckIgnoreLabels(t *testing.T) {
+	ready := createTestPR(2, false)
+	ready.Labels = []string{"ready"}
+	// Has both the required linked issue label and an ignored label.
+	wip := createTestPR(3, false)
+	wip.Labels = []string{"ready", "WIP"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{ready, wip}, nil)
+	github.EXPECT().ListLinkedIssues(gomock.Any(), 2).Times(1).Return([]resource.LinkedIssue{{Number: 10, Labels: []string{"priority:high"}}}, nil)
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:       "itsdalmo/test-repository",
+			AccessToken:      "oauthtoken",
+			LinkedIssueLabel: "priority:high",
+			IgnoreLabels:     []string{"wip", "do-not-merge"},
+		},
+		Version: resource.NewVersion(createTestPR(5, false)),
+	}
+	output, err := resource.Check(input, github)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := output, (resource.CheckResponse{resource.NewVersion(ready)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestCheckTitleRegex(t *testing.T) {
+	top := createTestPR(2, false)
+	top.Title = "[stack/feature-x] top of the stack"
+	other := createTestPR(3, false)
+	other.Title = "[feature-x] unrelated change"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{top, other}, nil)
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+			TitleRegex:  `^\[stack/[^\]]+\]`,
+		},
+		Version: resource.NewVersion(createTestPR(5, false)),
+	}
+	output, err := resource.Check(input, github)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := output, (resource.CheckResponse{resource.NewVersion(top)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestCheckDisableForks(t *testing.T) {
+	fork := createTestPR(2, false)
+	fork.IsCrossRepository = true
+	local := createTestPR(3, false)
+
+	tests := []struct {
+		description  string
+		disableForks string
+		expected     resource.CheckResponse
+	}{
+		{
+			description:  "forks are included by default",
+			disableForks: "",
+			expected: resource.CheckResponse{
+				resource.NewVersion(local),
+				resource.NewVersion(fork),
+			},
+		},
+		{
+			description:  "forks are skipped when disabled",
+			disableForks: "true",
+			expected: resource.CheckResponse{
+				resource.NewVersion(local),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{fork, local}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:   "itsdalmo/test-repository",
+					AccessToken:  "oauthtoken",
+					DisableForks: tc.disableForks,
+				},
+				Version: resource.NewVersion(testPullRequests[3]),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckSkipArchived(t *testing.T) {
+	archived := createTestPR(2, false)
+	archived.Repository.IsArchived = true
+	active := createTestPR(3, false)
+
+	tests := []struct {
+		description  string
+		skipArchived string
+		expected     resource.CheckResponse
+	}{
+		{
+			description:  "archived repositories are included by default",
+			skipArchived: "",
+			expected: resource.CheckResponse{
+				resource.NewVersion(active),
+				resource.NewVersion(archived),
+			},
+		},
+		{
+			description:  "archived repositories are skipped when enabled",
+			skipArchived: "true",
+			expected: resource.CheckResponse{
+				resource.NewVersion(active),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{archived, active}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:   "itsdalmo/test-repository",
+					AccessToken:  "oauthtoken",
+					SkipArchived: tc.skipArchived,
+				},
+				Version: resource.NewVersion(testPullRequests[3]),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckMilestone(t *testing.T) {
+	matching := createTestPR(2, false)
+	matching.Milestone = &resource.MilestoneObject{Title: "v1.2"}
+	other := createTestPR(3, false)
+	other.Milestone = &resource.MilestoneObject{Title: "v1.3"}
+	none := createTestPR(4, false)
+
+	tests := []struct {
+		description string
+		milestone   string
+		expected    resource.CheckResponse
+	}{
+		{
+			description: "all pull requests are included by default",
+			milestone:   "",
+			expected: resource.CheckResponse{
+				resource.NewVersion(none),
+				resource.NewVersion(other),
+				resource.NewVersion(matching),
+			},
+		},
+		{
+			description: "only pull requests in the milestone are included",
+			milestone:   "v1.2",
+			expected: resource.CheckResponse{
+				resource.NewVersion(matching),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{matching, other, none}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:  "itsdalmo/test-repository",
+					AccessToken: "oauthtoken",
+					Milestone:   tc.milestone,
+				},
+				Version: resource.NewVersion(createTestPR(5, false)),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckRequireAssociation(t *testing.T) {
+	member := createTestPR(2, false)
+	member.AuthorAssociation = githubv4.CommentAuthorAssociationMember
+	firstTimer := createTestPR(3, false)
+	firstTimer.AuthorAssociation = githubv4.CommentAuthorAssociationFirstTimeContributor
+
+	tests := []struct {
+		description        string
+		requireAssociation []string
+		expected           resource.CheckResponse
+	}{
+		{
+			description:        "all authors are included by default",
+			requireAssociation: nil,
+			expected: resource.CheckResponse{
+				resource.NewVersion(firstTimer),
+				resource.NewVersion(member),
+			},
+		},
+		{
+			description:        "outside contributors are excluded",
+			requireAssociation: []string{"MEMBER", "OWNER", "COLLABORATOR"},
+			expected: resource.CheckResponse{
+				resource.NewVersion(member),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{member, firstTimer}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:         "itsdalmo/test-repository",
+					AccessToken:        "oauthtoken",
+					RequireAssociation: tc.requireAssociation,
+				},
+				Version: resource.NewVersion(createTestPR(5, false)),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckSkipCIScanAllCommits(t *testing.T) {
+	// The marker is in an earlier commit, the tip of the PR is a regular commit.
+	marked := createTestPR(2, false)
+	clean := createTestPR(3, false)
+
+	tests := []struct {
+		description   string
+		scanAll       string
+		disableCISkip string
+		expected      resource.CheckResponse
+	}{
+		{
+			description: "only the tip is scanned by default",
+			scanAll:     "",
+			expected: resource.CheckResponse{
+				resource.NewVersion(clean),
+				resource.NewVersion(marked),
+			},
+		},
+		{
+			description: "a marker in any commit skips the pull request",
+			scanAll:     "true",
+			expected: resource.CheckResponse{
+				resource.NewVersion(clean),
+			},
+		},
+		{
+			description:   "nothing is skipped when disable_ci_skip is set",
+			scanAll:       "true",
+			disableCISkip: "true",
+			expected: resource.CheckResponse{
+				resource.NewVersion(clean),
+				resource.NewVersion(marked),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{marked, clean}, nil)
+			if tc.scanAll == "true" && tc.disableCISkip != "true" {
+				github.EXPECT().ListCommitMessages(gomock.Any(), marked.Number).Times(1).Return([]string{"wip [skip ci]", marked.Tip.Message}, nil)
+				github.EXPECT().ListCommitMessages(gomock.Any(), clean.Number).Times(1).Return([]string{"first", clean.Tip.Message}, nil)
+			}
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:           "itsdalmo/test-repository",
+					AccessToken:          "oauthtoken",
+					SkipCIScanAllCommits: tc.scanAll,
+					DisableCISkip:        tc.disableCISkip,
+				},
+				Version: resource.NewVersion(createTestPR(5, false)),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckMaxCommits(t *testing.T) {
+	small := createTestPR(2, false)
+	small.CommitCount.TotalCount = 3
+	large := createTestPR(3, false)
+	large.CommitCount.TotalCount = 30
+
+	tests := []struct {
+		description string
+		maxCommits  int
+		expected    resource.CheckResponse
+	}{
+		{
+			description: "the number of commits is not limited by default",
+			maxCommits:  0,
+			expected: resource.CheckResponse{
+				resource.NewVersion(large),
+				resource.NewVersion(small),
+			},
+		},
+		{
+			description: "pull requests over the limit are excluded",
+			maxCommits:  10,
+			expected: resource.CheckResponse{
+				resource.NewVersion(small),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{small, large}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:  "itsdalmo/test-repository",
+					AccessToken: "oauthtoken",
+					MaxCommits:  tc.maxCommits,
+				},
+				Version: resource.NewVersion(createTestPR(5, false)),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckErrorClassification(t *testing.T) {
+	tests := []struct {
+		description string
+		err         error
+		want        error
+	}{
+		{
+			description: "a rejected access token",
+			err:         &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnauthorized}, Message: "Bad credentials"},
+			want:        resource.ErrAuth,
+		},
+		{
+			description: "a rejected access token (V4 API)",
+			err:         errors.New(`non-200 OK status code: 401 Unauthorized body: "{\"message\": \"Bad credentials\"}"`),
+			want:        resource.ErrAuth,
+		},
+		{
+			description: "an exceeded rate limit",
+			err:         &github.RateLimitError{Response: &http.Response{StatusCode: http.StatusForbidden}, Message: "API rate limit exceeded"},
+			want:        resource.ErrRateLimited,
+		},
+		{
+			description: "an exceeded rate limit (V4 API)",
+			err:         errors.New("API rate limit exceeded for user ID 1."),
+			want:        resource.ErrRateLimited,
+		},
+		{
+			description: "a missing repository",
+			err:         &resource.RepositoryUnavailableError{Repository: "itsdalmo/test-repository"},
+			want:        resource.ErrRepoNotFound,
+		},
+		{
+			description: "other errors are not classified",
+			err:         errors.New("something went wrong"),
+			want:        nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			manager := mocks.NewMockGithub(ctrl)
+			manager.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(nil, tc.err)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+			}
+			_, err := resource.Check(input, manager)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, sentinel := range []error{resource.ErrAuth, resource.ErrRateLimited, resource.ErrRepoNotFound} {
+				if got, want := errors.Is(err, sentinel), sentinel == tc.want; got != want {
+					t.Errorf("errors.Is(%v, %v): got %v, want %v", err, sentinel, got, want)
+				}
+			}
+			// The underlying error is still available.
+			if target := reflect.New(reflect.TypeOf(tc.err)); !errors.As(err, target.Interface()) {
+				t.Errorf("expected errors.As to find the %T in: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckVersionStrategy(t *testing.T) {
+	date := time.Date(2018, 5, 14, 8, 0, 0, 0, time.UTC)
+	current := createTestPR(1, false)
+	current.Tip.CommittedDate = githubv4.DateTime{Time: date}
+
+	// A commit on another PR with the same timestamp as the current version.
+	sameTime := createTestPR(2, false)
+	sameTime.Tip.CommittedDate = githubv4.DateTime{Time: date}
+	// The current PR was force-pushed to a commit with an older timestamp.
+	forcePushed := createTestPR(1, false)
+	forcePushed.Tip.OID = "oid1-rewritten"
+	forcePushed.Tip.CommittedDate = githubv4.DateTime{Time: date.Add(-time.Hour)}
+	older := createTestPR(3, false)
+	older.Tip.CommittedDate = githubv4.DateTime{Time: date.Add(-time.Hour)}
+
+	tests := []struct {
+		description     string
+		versionStrategy string
+		pullRequests    []*resource.PullRequest
+		expected        resource.CheckResponse
+	}{
+		{
+			description:     "commits sharing the timestamp of the current version are not newer by date",
+			versionStrategy: "",
+			pullRequests:    []*resource.PullRequest{forcePushed, sameTime, older},
+			expected:        resource.CheckResponse{resource.NewVersion(current)},
+		},
+		{
+			description:     "commits sharing the timestamp of the current version are distinguished by sha",
+			versionStrategy: "sha",
+			pullRequests:    []*resource.PullRequest{forcePushed, sameTime, older},
+			expected: resource.CheckResponse{
+				resource.NewVersion(forcePushed),
+				resource.NewVersion(sameTime),
+			},
+		},
+		{
+			description:     "the commit of the current version is not new by sha",
+			versionStrategy: "sha",
+			pullRequests:    []*resource.PullRequest{current, older},
+			expected:        resource.CheckResponse{resource.NewVersion(current)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(tc.pullRequests, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:      "itsdalmo/test-repository",
+					AccessToken:     "oauthtoken",
+					VersionStrategy: tc.versionStrategy,
+				},
+				Version: resource.NewVersion(current),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckSkipAutoMerge(t *testing.T) {
+	queued := createTestPR(2, false)
+	queued.AutoMergeRequest = &resource.AutoMergeRequestObject{}
+	notQueued := createTestPR(3, false)
+
+	tests := []struct {
+		description   string
+		skipAutoMerge string
+		expected      resource.CheckResponse
+	}{
+		{
+			description:   "pull requests queued for auto-merge are included by default",
+			skipAutoMerge: "",
+			expected: resource.CheckResponse{
+				resource.NewVersion(notQueued),
+				resource.NewVersion(queued),
+			},
+		},
+		{
+			description:   "pull requests queued for auto-merge are skipped when enabled",
+			skipAutoMerge: "true",
+			expected: resource.CheckResponse{
+				resource.NewVersion(notQueued),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{queued, notQueued}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:    "itsdalmo/test-repository",
+					AccessToken:   "oauthtoken",
+					SkipAutoMerge: tc.skipAutoMerge,
+				},
+				Version: resource.NewVersion(testPullRequests[3]),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckFailOnArchived(t *testing.T) {
+	pull := createTestPR(2, false)
+
+	tests := []struct {
+		description    string
+		failOnArchived string
+		expect         func(github *mocks.MockGithub)
+		expected       resource.CheckResponse
+		wantErr        string
+	}{
+		{
+			description:    "archived repositories are checked by default",
+			failOnArchived: "",
+			expect: func(github *mocks.MockGithub) {
+				github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{pull}, nil)
+			},
+			expected: resource.CheckResponse{resource.NewVersion(pull)},
+		},
+		{
+			description:    "active repositories are checked when enabled",
+			failOnArchived: "true",
+			expect: func(github *mocks.MockGithub) {
+				github.EXPECT().GetRepository(gomock.Any()).Times(1).Return(&resource.RepositoryObject{}, nil)
+				github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{pull}, nil)
+			},
+			expected: resource.CheckResponse{resource.NewVersion(pull)},
+		},
+		{
+			description:    "archived repositories fail when enabled",
+			failOnArchived: "true",
+			expect: func(github *mocks.MockGithub) {
+				github.EXPECT().GetRepository(gomock.Any()).Times(1).Return(&resource.RepositoryObject{IsArchived: true}, nil)
+			},
+			wantErr: "repository itsdalmo/test-repository is archived",
+		},
+		{
+			description:    "missing repositories always fail",
+			failOnArchived: "",
+			expect: func(github *mocks.MockGithub) {
+				github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(nil, &resource.RepositoryUnavailableError{Repository: "itsdalmo/test-repository"})
+			},
+			wantErr: "repository itsdalmo/test-repository was not found (or is not accessible with the access token)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			tc.expect(github)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:     "itsdalmo/test-repository",
+					AccessToken:    "oauthtoken",
+					FailOnArchived: tc.failOnArchived,
+				},
+				Version: resource.NewVersion(testPullRequests[3]),
+			}
+			output, err := resource.Check(input, github)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckSubSecondPrecision(t *testing.T) {
+	date := time.Date(2018, time.May, 14, 10, 51, 58, 500000000, time.UTC)
+	seen := createTestPR(2, false)
+	seen.Tip.CommittedDate = githubv4.DateTime{Time: date}
+	newer := createTestPR(3, false)
+	newer.Tip.CommittedDate = githubv4.DateTime{Time: date.Add(time.Millisecond)}
+
+	// The version is stored by Concourse as JSON, so round trip it to compare with the same precision.
+	var version resource.Version
+	b, err := json.Marshal(resource.NewVersion(seen))
+	if err != nil {
+		t.Fatalf("failed to marshal version: %s", err)
+	}
+	if err := json.Unmarshal(b, &version); err != nil {
+		t.Fatalf("failed to unmarshal version: %s", err)
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{seen, newer}, nil)
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+		},
+		Version: version,
+	}
+	output, err := resource.Check(input, github)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := output, (resource.CheckResponse{resource.NewVersion(newer)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestCheckGithubOrderBy(t *testing.T) {
+	tests := []struct {
+		description string
+		orderBy     *resource.PullRequestOrder
+		expected    *githubv4.IssueOrder
+	}{
+		{
+			description: "order is left to github by default",
+			orderBy:     nil,
+			expected:    nil,
+		},
+		{
+			description: "direction defaults to ascending",
+			orderBy:     &resource.PullRequestOrder{Field: "CREATED_AT"},
+			expected:    &githubv4.IssueOrder{Field: githubv4.IssueOrderFieldCreatedAt, Direction: githubv4.OrderDirectionAsc},
+		},
+		{
+			description: "order reflects the configuration",
+			orderBy:     &resource.PullRequestOrder{Field: "updated_at", Direction: "desc"},
+			expected:    &githubv4.IssueOrder{Field: githubv4.IssueOrderFieldUpdatedAt, Direction: githubv4.OrderDirectionDesc},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, tc.expected).Times(1).Return(nil, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:    "itsdalmo/test-repository",
+					AccessToken:   "oauthtoken",
+					GithubOrderBy: tc.orderBy,
+				},
+			}
+			if err := input.Source.Validate(); err != nil {
+				t.Fatalf("unexpected validation error: %s", err)
+			}
+			if _, err := resource.Check(input, github); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheckVersionKey(t *testing.T) {
+	// A force-push lowered the committed date of the tip, but the PR was updated after the last version.
+	previous := createTestPR(2, false)
+	pull := createTestPR(3, false)
+	pull.UpdatedAt = githubv4.DateTime{Time: time.Now()}
+
+	tests := []struct {
+		description string
+		versionKey  string
+		expected    resource.CheckResponse
+	}{
+		{
+			description: "committed date ignores the force-pushed PR",
+			versionKey:  "committed",
+			expected: resource.CheckResponse{
+				resource.NewVersion(previous),
+			},
+		},
+		{
+			description: "updated date registers the force-pushed PR as new",
+			versionKey:  "updated",
+			expected: resource.CheckResponse{
+				resource.Version{PR: "3", Commit: "oid3", CommittedDate: pull.UpdatedAt.Time},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{pull}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:  "itsdalmo/test-repository",
+					AccessToken: "oauthtoken",
+					VersionKey:  tc.versionKey,
+				},
+				Version: resource.NewVersion(previous),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckTriggerOnReopen(t *testing.T) {
+	// The PR was built, closed and then reopened without any new commits.
+	pull := createTestPR(2, false)
+	previous := resource.NewVersion(pull)
+	pull.ReopenedAt = githubv4.DateTime{Time: time.Now()}
+
+	tests := []struct {
+		description     string
+		triggerOnReopen string
+		expected        resource.CheckResponse
+	}{
+		{
+			description:     "reopened PR does not produce a new version by default",
+			triggerOnReopen: "",
+			expected: resource.CheckResponse{
+				previous,
+			},
+		},
+		{
+			description:     "reopened PR produces a new version when enabled",
+			triggerOnReopen: "true",
+			expected: resource.CheckResponse{
+				resource.Version{PR: "2", Commit: "oid2", CommittedDate: pull.ReopenedAt.Time},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{pull}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:      "itsdalmo/test-repository",
+					AccessToken:     "oauthtoken",
+					TriggerOnReopen: tc.triggerOnReopen,
+				},
+				Version: previous,
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckConcurrency(t *testing.T) {
+	var pullRequests []*resource.PullRequest
+	files := make(map[int][]string)
+	for i := 1; i <= 20; i++ {
+		p := createTestPR(i, false)
+		pullRequests = append(pullRequests, p)
+		if i%3 == 0 {
+			files[i] = []string{"README.md"}
+		} else {
+			files[i] = []string{"README.md", "terraform/main.tf"}
+		}
+	}
+	// Give some pull requests the same committed date to verify that ties are ordered consistently.
+	pullRequests[4].Tip.CommittedDate = pullRequests[5].Tip.CommittedDate
+	pullRequests[10].Tip.CommittedDate = pullRequests[11].Tip.CommittedDate
+
+	check := func(concurrency int) resource.CheckResponse {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		github := mocks.NewMockGithub(ctrl)
+		github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(pullRequests, nil)
+		for number, f := range files {
+			github.EXPECT().ListModifiedFiles(gomock.Any(), number).Times(1).Return(f, nil)
+		}
+
+		input := resource.CheckRequest{
+			Source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				Paths:       []string{"terraform/*.tf"},
+				IgnorePaths: []string{"*.md"},
+				Concurrency: concurrency,
+			},
+			Version: resource.Version{PR: "100", CommittedDate: time.Now().AddDate(-1, 0, 0)},
+		}
+		output, err := resource.Check(input, github)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return output
+	}
+
+	serial := check(1)
+	if len(serial) == 0 {
+		t.Fatal("expected versions to be returned")
+	}
+	for i := 0; i < 10; i++ {
+		if got, want := check(8), serial; !reflect.DeepEqual(got, want) {
+			t.Fatalf("\ngot:\n%v\nwant:\n%v\n", got, want)
+		}
+	}
+}
+
+func TestCheckConcurrencyAbortsOnError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(testPullRequests, nil)
+	github.EXPECT().ListModifiedFiles(gomock.Any(), 2).AnyTimes().Return(nil, errors.New("boom"))
+	github.EXPECT().ListModifiedFiles(gomock.Any(), 3).AnyTimes().Return([]string{"README.md"}, nil)
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+			IgnorePaths: []string{"*.tf"},
+		},
+		Version: resource.NewVersion(testPullRequests[3]),
+	}
+	if _, err := resource.Check(input, github); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestCheckStopsAtFirstMatchingPath(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(testPullRequests, nil)
+	github.EXPECT().ListModifiedFilesPage(gomock.Any(), 2, 1).Times(1).Return([]string{"README.md", "terraform/main.tf"}, 2, nil)
+	github.EXPECT().ListModifiedFilesPage(gomock.Any(), 2, 2).AnyTimes().Return(nil, 0, errors.New("second page should not be fetched"))
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+			Paths:       []string{"terraform/*.tf"},
+		},
+		Version: resource.NewVersion(testPullRequests[2]),
+	}
+	output, err := resource.Check(input, github)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := output, (resource.CheckResponse{resource.NewVersion(testPullRequests[1])}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestCheckRespectExportIgnore(t *testing.T) {
+	files := map[int][]string{
+		2: {"api/server.go"},
+		3: {"api/generated/client.go", "README.md"},
+	}
+	attributes := "# Generated code\napi/generated/** export-ignore linguist-generated\n*.pb.go export-ignore\n"
+
+	tests := []struct {
+		description         string
+		respectExportIgnore string
+		expected            resource.CheckResponse
+	}{
+		{
+			description:         "export-ignored files match paths by default",
+			respectExportIgnore: "",
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[2]),
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+		{
+			description:         "export-ignored files do not match paths when respected",
+			respectExportIgnore: "true",
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(testPullRequests, nil)
+			for number, f := range files {
+				p := testPullRequests[number-1]
+				if tc.respectExportIgnore != "" {
+					github.EXPECT().ListModifiedFiles(gomock.Any(), number).Times(1).Return(f, nil)
+					github.EXPECT().GetFileContent(gomock.Any(), ".gitattributes", p.Tip.OID).Times(1).Return(attributes, nil)
+				} else {
+					github.EXPECT().ListModifiedFilesPage(gomock.Any(), number, 1).Times(1).Return(f, 0, nil)
+				}
+			}
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:          "itsdalmo/test-repository",
+					AccessToken:         "oauthtoken",
+					Paths:               []string{"api/*", "api/*/*"},
+					RespectExportIgnore: tc.respectExportIgnore,
+				},
+				Version: resource.NewVersion(testPullRequests[3]),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckTrace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(testPullRequests, nil)
+	github.EXPECT().ListModifiedFilesPage(gomock.Any(), 2, 1).Times(1).Return([]string{"README.md"}, 0, nil)
+	github.EXPECT().ListModifiedFilesPage(gomock.Any(), 3, 1).Times(1).Return([]string{"terraform/main.tf"}, 0, nil)
+
+	// Capture stderr while running check.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+			Paths:       []string{"terraform/*.tf"},
+			Trace:       "true",
+		},
+		Version: resource.NewVersion(testPullRequests[3]),
+	}
+	output, err := resource.Check(input, github)
+	w.Close()
+	os.Stderr = stderr
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read stderr: %s", err)
+	}
+
+	if got, want := output, (resource.CheckResponse{resource.NewVersion(testPullRequests[2])}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if want := "PR #2 skipped: no files match paths"; !strings.Contains(string(b), want) {
+		t.Errorf("expected stderr to contain %q, got:\n%s", want, b)
+	}
+}
+
+func TestCheckSummary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(testPullRequests, nil)
+	github.EXPECT().ListModifiedFilesPage(gomock.Any(), 2, 1).Times(1).Return([]string{"README.md"}, 0, nil)
+	github.EXPECT().ListModifiedFilesPage(gomock.Any(), 3, 1).Times(1).Return([]string{"terraform/main.tf"}, 0, nil)
+
+	os.Setenv("GITHUB_PR_CHECK_SUMMARY", "true")
+	defer os.Unsetenv("GITHUB_PR_CHECK_SUMMARY")
+
+	// Capture stderr while running check.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+			Paths:       []string{"terraform/*.tf"},
+		},
+		Version: resource.NewVersion(testPullRequests[3]),
+	}
+	_, err = resource.Check(input, github)
+	w.Close()
+	os.Stderr = stderr
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read stderr: %s", err)
+	}
+
+	var got struct {
+		PullRequests int            `json:"pull_requests"`
+		Skipped      map[string]int `json:"skipped"`
+		Versions     int            `json:"versions"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed to unmarshal summary: %s: %s", err, b)
+	}
+	if got.PullRequests != 4 {
+		t.Errorf("expected 4 pull requests, got %d", got.PullRequests)
+	}
+	if want := map[string]int{"ci_skip": 1, "not_newer": 1, "paths": 1}; !reflect.DeepEqual(got.Skipped, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Skipped, want)
+	}
+	if got.Versions != 1 {
+		t.Errorf("expected 1 version, got %d", got.Versions)
+	}
+}
+
+func TestCheckSummaryRepositories(t *testing.T) {
+	// Pull requests in different repositories can have the same number.
+	api := createTestPR(1, false)
+	web := createTestPR(2, false)
+	web.Number = 1
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	managers := map[string]*mocks.MockGithub{
+		"itsdalmo/api": mocks.NewMockGithub(ctrl),
+		"itsdalmo/web": mocks.NewMockGithub(ctrl),
+	}
+	managers["itsdalmo/api"].EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{api}, nil)
+	managers["itsdalmo/web"].EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{web}, nil)
+	newGithub := func(repository string) (resource.Github, error) {
+		return managers[repository], nil
+	}
+
+	os.Setenv("GITHUB_PR_CHECK_SUMMARY", "true")
+	defer os.Unsetenv("GITHUB_PR_CHECK_SUMMARY")
+
+	// Capture stderr while running check.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repositories: []string{"itsdalmo/api", "itsdalmo/web"},
+			AccessToken:  "oauthtoken",
+		},
+		Version: resource.NewVersion(createTestPR(5, false)),
+	}
+	_, err = resource.CheckRepositories(input, newGithub)
+	w.Close()
+	os.Stderr = stderr
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read stderr: %s", err)
+	}
+
+	var got struct {
+		PullRequests int `json:"pull_requests"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed to unmarshal summary: %s: %s", err, b)
+	}
+	if got.PullRequests != 2 {
+		t.Errorf("expected 2 pull requests, got %d", got.PullRequests)
+	}
+}
+
+func TestContainsSkipCI(t *testing.T) {
+	tests := []struct {
+		description string
+		message     string
+		want        bool
+	}{
+		{
+			description: "does not just match any symbol in the regexp",
+			message:     "(",
+			want:        false,
+		},
+		{
+			description: "does not match when it should not",
+			message:     "test",
+			want:        false,
+		},
+		{
+			description: "matches [ci skip]",
+			message:     "[ci skip]",
+			want:        true,
+		},
+		{
+			description: "matches [skip ci]",
+			message:     "[skip ci]",
+			want:        true,
+		},
+		{
+			description: "matches trailing skip ci",
+			message:     "trailing [skip ci]",
+			want:        true,
+		},
+		{
+			description: "matches leading skip ci",
+			message:     "[skip ci] leading",
+			want:        true,
+		},
+		{
+			description: "is case insensitive",
+			message:     "case[Skip CI]insensitive",
+			want:        true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			got := resource.ContainsSkipCI(tc.message)
+			if got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterPath(t *testing.T) {
+	cases := []struct {
+		description string
+		pattern     string
+		files       []string
+		want        []string
+	}{
+		{
+			description: "returns all matching files",
+			pattern:     "*.txt",
+			files: []string{
+				"file1.txt",
+				"test/file2.txt",
+			},
+			want: []string{
+				"file1.txt",
+			},
+		},
+		{
+			description: "works with wildcard",
+			pattern:     "test/*",
+			files: []string{
+				"file1.txt",
+				"test/file2.txt",
+			},
+			want: []string{
+				"test/file2.txt",
+			},
+		},
+		{
+			description: "excludes unmatched files",
+			pattern:     "*/*.txt",
+			files: []string{
+				"test/file1.go",
+				"test/file2.txt",
+			},
+			want: []string{
+				"test/file2.txt",
+			},
+		},
+		{
+			description: "normalizes windows path separators",
+			pattern:     "terraform/*/*.tf",
+			files: []string{
+				`terraform\modules\main.tf`,
+				`docs\README.md`,
+			},
+			want: []string{
+				`terraform\modules\main.tf`,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := resource.FilterPath(tc.files, tc.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%s\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterIgnorePath(t *testing.T) {
+	cases := []struct {
+		description string
+		pattern     string
+		files       []string
+		want        []string
+	}{
+		{
+			description: "excludes all matching files",
+			pattern:     "*.txt",
+			files: []string{
+				"file1.txt",
+				"test/file2.txt",
+			},
+			want: []string{
+				"test/file2.txt",
+			},
+		},
+		{
+			description: "works with wildcard",
+			pattern:     "test/*",
+			files: []string{
+				"file1.txt",
+				"test/file2.txt",
+			},
+			want: []string{
+				"file1.txt",
+			},
+		},
+		{
+			description: "includes unmatched files",
+			pattern:     "*/*.txt",
+			files: []string{
+				"test/file1.go",
+				"test/file2.txt",
+			},
+			want: []string{
+				"test/file1.go",
+			},
+		},
+		{
+			description: "normalizes windows path separators",
+			pattern:     "terraform/*",
+			files: []string{
+				`terraform\main.tf`,
+				`docs\README.md`,
+			},
+			want: []string{
+				`docs\README.md`,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := resource.FilterIgnorePath(tc.files, tc.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%s\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckBatchMode(t *testing.T) {
+	older := createTestPR(3, false)
+	newer := createTestPR(2, false)
+	batch := resource.NewBatchVersion([]resource.Version{
+		resource.NewVersion(newer),
+		resource.NewVersion(older),
+	})
+
+	tests := []struct {
+		description   string
+		version       resource.Version
+		maxTrackedPRs int
+		expected      resource.CheckResponse
+	}{
+		{
+			description: "returns a batch of all matching prs",
+			version:     resource.Version{},
+			expected:    resource.CheckResponse{batch},
+		},
+		{
+			description: "returns the previous version if the batch is unchanged",
+			version:     batch,
+			expected:    resource.CheckResponse{batch},
+		},
+		{
+			description: "returns a new batch when the prs have changed",
+			version:     resource.Version{Batch: "2:oid2"},
+			expected:    resource.CheckResponse{batch},
+		},
+		{
+			description: "drops prs that are no longer open",
+			version:     resource.Version{Batch: "2:oid2,3:oid3,5:oid5"},
+			expected:    resource.CheckResponse{batch},
+		},
+		{
+			description:   "keeps the most recent prs up to max_tracked_prs",
+			version:       resource.Version{},
+			maxTrackedPRs: 1,
+			expected:      resource.CheckResponse{resource.NewBatchVersion([]resource.Version{resource.NewVersion(newer)})},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{older, newer}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:    "itsdalmo/test-repository",
+					AccessToken:   "oauthtoken",
+					BatchMode:     "true",
+					MaxTrackedPRs: tc.maxTrackedPRs,
+				},
+				Version: tc.version,
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckWebhookOptimized(t *testing.T) {
+	previous := testPullRequests[3]
+	pushed := createTestPR(previous.Number, false)
+	pushed.Tip.OID = previous.Tip.OID + "-new"
+	pushed.Tip.CommittedDate = githubv4.DateTime{Time: previous.Tip.CommittedDate.Time.Add(time.Hour)}
+
+	tests := []struct {
+		description      string
+		webhookOptimized string
+		version          resource.Version
+		getPR            bool
+		expected         resource.CheckResponse
+	}{
+		{
+			description:      "fetches only the pr of the current version",
+			webhookOptimized: "true",
+			version:          resource.NewVersion(previous),
+			getPR:            true,
+			expected: resource.CheckResponse{
+				resource.NewVersion(pushed),
+			},
+		},
+		{
+			description:      "lists all prs without a current version",
+			webhookOptimized: "true",
+			version:          resource.Version{},
+			getPR:            false,
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+		{
+			description:      "lists all prs when disabled",
+			webhookOptimized: "",
+			version:          resource.NewVersion(previous),
+			getPR:            false,
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[2]),
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			if tc.getPR {
+				github.EXPECT().GetPullRequest(gomock.Any(), tc.version.PR, "").Times(1).Return(pushed, nil)
+			} else {
+				github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(testPullRequests, nil)
+			}
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:       "itsdalmo/test-repository",
+					AccessToken:      "oauthtoken",
+					WebhookOptimized: tc.webhookOptimized,
+				},
+				Version: tc.version,
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckPathsSkipOnFirstRun(t *testing.T) {
+	tests := []struct {
+		description         string
+		pathsSkipOnFirstRun string
+		version             resource.Version
+		listFiles           bool
+		expected            resource.CheckResponse
+	}{
+		{
+			description:         "paths are not enforced on the first run",
+			pathsSkipOnFirstRun: "true",
+			version:             resource.Version{},
+			listFiles:           false,
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[1]),
+			},
+		},
+		{
+			description:         "paths are enforced on subsequent runs",
+			pathsSkipOnFirstRun: "true",
+			version:             resource.NewVersion(testPullRequests[3]),
+			listFiles:           true,
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[2]),
+			},
+		},
+		{
+			description:         "paths are enforced on the first run by default",
+			pathsSkipOnFirstRun: "",
+			version:             resource.Version{},
+			listFiles:           true,
+			expected: resource.CheckResponse{
+				resource.NewVersion(testPullRequests[2]),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(testPullRequests, nil)
+			if tc.listFiles {
+				github.EXPECT().ListModifiedFilesPage(gomock.Any(), 2, 1).Times(1).Return([]string{"README.md"}, 0, nil)
+				github.EXPECT().ListModifiedFilesPage(gomock.Any(), 3, 1).Times(1).Return([]string{"terraform/main.tf"}, 0, nil)
+				github.EXPECT().ListModifiedFilesPage(gomock.Any(), 4, 1).AnyTimes().Return([]string{"README.md"}, 0, nil)
+			}
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:          "itsdalmo/test-repository",
+					AccessToken:         "oauthtoken",
+					Paths:               []string{"terraform/*.tf"},
+					PathsSkipOnFirstRun: tc.pathsSkipOnFirstRun,
+				},
+				Version: tc.version,
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckSince(t *testing.T) {
+	date := time.Date(2018, time.May, 14, 10, 51, 58, 0, time.UTC)
+	old := createTestPR(2, false)
+	old.UpdatedAt = githubv4.DateTime{Time: date.Add(-time.Hour)}
+	recent := createTestPR(3, false)
+	recent.UpdatedAt = githubv4.DateTime{Time: date.Add(time.Hour)}
+
+	tests := []struct {
+		description string
+		sincePR     int
+		sinceDate   string
+		expected    resource.CheckResponse
+	}{
+		{
+			description: "all prs are included without a cutoff",
+			expected: resource.CheckResponse{
+				resource.NewVersion(old),
+			},
+		},
+		{
+			description: "prs with a lower number are skipped",
+			sincePR:     3,
+			expected: resource.CheckResponse{
+				resource.NewVersion(recent),
+			},
+		},
+		{
+			description: "prs last updated before the date are skipped",
+			sinceDate:   date.Format(time.RFC3339),
+			expected: resource.CheckResponse{
+				resource.NewVersion(recent),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{old, recent}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:  "itsdalmo/test-repository",
+					AccessToken: "oauthtoken",
+					SincePR:     tc.sincePR,
+					SinceDate:   tc.sinceDate,
+				},
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output, tc.expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckRequireStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected bool
+	}{
+		{status: "SUCCESS", expected: true},
+		{status: "FAILURE", expected: false},
+		{status: "PENDING", expected: false},
+		{status: "EXPECTED", expected: false},
+		{status: "", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("status %q", tc.status), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(2, false)
+			if tc.status != "" {
+				pull.Tip.StatusCheckRollup = &struct{ State string }{State: tc.status}
+			}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{pull}, nil)
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:    "itsdalmo/test-repository",
+					AccessToken:   "oauthtoken",
+					RequireStatus: "success",
+				},
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var expected resource.CheckResponse
+			if tc.expected {
+				expected = resource.CheckResponse{resource.NewVersion(pull)}
+			}
+			if got, want := output, expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckRequiredStatusChecks(t *testing.T) {
+	tests := []struct {
+		description string
+		checks      []string
+		protected   []string
+		states      map[int]map[string]string
+		expected    []int
+	}{
+		{
+			description: "auto adds the contexts required by branch protection to the explicit ones",
+			checks:      []string{"auto", "lint"},
+			protected:   []string{"ci/build"},
+			states: map[int]map[string]string{
+				2: {"ci/build": "SUCCESS", "lint": "SUCCESS"},
+				3: {"ci/build": "SUCCESS"},
+			},
+			expected: []int{2},
+		},
+		{
+			description: "auto requires no contexts for an unprotected branch",
+			checks:      []string{"auto"},
+			protected:   nil,
+			expected:    []int{3, 2},
+		},
+		{
+			description: "explicit contexts must have passed",
+			checks:      []string{"ci/build"},
+			states: map[int]map[string]string{
+				2: {"ci/build": "FAILURE"},
+				3: {"ci/build": "SUCCESS", "lint": "PENDING"},
+			},
+			expected: []int{3},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pulls := []*resource.PullRequest{createTestPR(2, false), createTestPR(3, false)}
+			for _, p := range pulls {
+				p.BaseRefName = "master"
+			}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return(pulls, nil)
+			for _, c := range tc.checks {
+				if c == "auto" {
+					// Branch protection is looked up once per base branch.
+					github.EXPECT().GetRequiredStatusContexts(gomock.Any(), "master").Times(1).Return(tc.protected, nil)
+				}
+			}
+			for _, p := range pulls {
+				if states, ok := tc.states[p.Number]; ok {
+					github.EXPECT().GetStatusContexts(gomock.Any(), p.Tip.OID).Times(1).Return(states, nil)
+				}
+			}
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repository:           "itsdalmo/test-repository",
+					AccessToken:          "oauthtoken",
+					RequiredStatusChecks: tc.checks,
+				},
+				Version: resource.NewVersion(createTestPR(5, false)),
+			}
+			output, err := resource.Check(input, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var expected resource.CheckResponse
+			for _, n := range tc.expected {
+				for _, p := range pulls {
+					if p.Number == n {
+						expected = append(expected, resource.NewVersion(p))
+					}
+				}
+			}
+			if got, want := output, expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+func TestCheckRepositories(t *testing.T) {
+	// Pull requests in different repositories can have the same number.
+	api := createTestPR(1, false)
+	web := createTestPR(2, false)
+	web.Number = 1
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	managers := map[string]*mocks.MockGithub{
+		"itsdalmo/api": mocks.NewMockGithub(ctrl),
+		"itsdalmo/web": mocks.NewMockGithub(ctrl),
+	}
+	managers["itsdalmo/api"].EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{api}, nil)
+	managers["itsdalmo/web"].EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{web}, nil)
+	newGithub := func(repository string) (resource.Github, error) {
+		return managers[repository], nil
+	}
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			Repositories: []string{"itsdalmo/api", "itsdalmo/web"},
+			AccessToken:  "oauthtoken",
+		},
+		Version: resource.NewVersion(createTestPR(5, false)),
+	}
+	if err := input.Source.Validate(); err != nil {
+		t.Fatalf("invalid source: %s", err)
+	}
+	output, err := resource.CheckRepositories(input, newGithub)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantAPI, wantWeb := resource.NewVersion(api), resource.NewVersion(web)
+	wantAPI.Repository, wantWeb.Repository = "itsdalmo/api", "itsdalmo/web"
+	if got, want := output, (resource.CheckResponse{wantWeb, wantAPI}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	// The version is passed back to get, which must use the repository it names.
+	for _, v := range output {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal version: %s", err)
+		}
+		var version resource.Version
+		if err := json.Unmarshal(b, &version); err != nil {
+			t.Fatalf("failed to unmarshal version: %s", err)
+		}
+		if got, want := input.Source.ForVersion(version).Repository, v.Repository; got != want {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+		}
+	}
+}
+
+func TestCheckRepositoriesSkipArchived(t *testing.T) {
+	active := createTestPR(1, false)
+	archived := createTestPR(2, false)
+	archived.Repository.IsArchived = true
+
+	tests := []struct {
+		description  string
+		skipArchived string
+		expected     []*resource.PullRequest
+	}{
+		{
+			description:  "archived repositories are skipped by default",
+			skipArchived: "",
+			expected:     []*resource.PullRequest{active},
+		},
+		{
+			description:  "archived repositories are included when disabled",
+			skipArchived: "false",
+			expected:     []*resource.PullRequest{archived, active},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			managers := map[string]*mocks.MockGithub{
+				"itsdalmo/api": mocks.NewMockGithub(ctrl),
+				"itsdalmo/old": mocks.NewMockGithub(ctrl),
+			}
+			managers["itsdalmo/api"].EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{active}, nil)
+			managers["itsdalmo/old"].EXPECT().ListPullRequests(gomock.Any(), openStates, 1, nil).Times(1).Return([]*resource.PullRequest{archived}, nil)
+			newGithub := func(repository string) (resource.Github, error) {
+				return managers[repository], nil
+			}
+
+			input := resource.CheckRequest{
+				Source: resource.Source{
+					Repositories: []string{"itsdalmo/api", "itsdalmo/old"},
+					AccessToken:  "oauthtoken",
+					SkipArchived: tc.skipArchived,
+				},
+				Version: resource.NewVersion(createTestPR(5, false)),
+			}
+			output, err := resource.CheckRepositories(input, newGithub)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var expected resource.CheckResponse
+			for _, p := range tc.expected {
+				v := resource.NewVersion(p)
+				v.Repository = "itsdalmo/api"
+				if p == archived {
+					v.Repository = "itsdalmo/old"
+				}
+				expected = append(expected, v)
+			}
+			if got, want := output, expected; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckSearchQuery(t *testing.T) {
+	api := createTestPR(1, false)
+	api.Repository.NameWithOwner = "itsdalmo/api"
+	web := createTestPR(2, false)
+	web.Repository.NameWithOwner = "itsdalmo/web"
+	docs := createTestPR(3, false)
+	docs.Repository.NameWithOwner = "itsdalmo/web"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The search uses a manager without a repository, the paths are listed in the repository of each PR.
+	managers := map[string]*mocks.MockGithub{
+		"":             mocks.NewMockGithub(ctrl),
+		"itsdalmo/api": mocks.NewMockGithub(ctrl),
+		"itsdalmo/web": mocks.NewMockGithub(ctrl),
+	}
+	managers[""].EXPECT().SearchPullRequests(gomock.Any(), "is:open org:itsdalmo", 1).Times(1).Return([]*resource.PullRequest{api, web, docs}, nil)
+	managers["itsdalmo/api"].EXPECT().ListModifiedFilesPage(gomock.Any(), api.Number, 1).Times(1).Return([]string{"infra/main.tf"}, 0, nil)
+	managers["itsdalmo/web"].EXPECT().ListModifiedFilesPage(gomock.Any(), web.Number, 1).Times(1).Return([]string{"infra/main.tf"}, 0, nil)
+	managers["itsdalmo/web"].EXPECT().ListModifiedFilesPage(gomock.Any(), docs.Number, 1).Times(1).Return([]string{"README.md"}, 0, nil)
+	newGithub := func(repository string) (resource.Github, error) {
+		return managers[repository], nil
+	}
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			SearchQuery: "org:itsdalmo",
+			AccessToken: "oauthtoken",
+			Paths:       []string{"infra/*"},
+		},
+		Version: resource.NewVersion(createTestPR(5, false)),
+	}
+	if err := input.Source.Validate(); err != nil {
+		t.Fatalf("invalid source: %s", err)
+	}
+	output, err := resource.CheckRepositories(input, newGithub)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantAPI, wantWeb := resource.NewVersion(api), resource.NewVersion(web)
+	wantAPI.Repository, wantWeb.Repository = "itsdalmo/api", "itsdalmo/web"
+	if got, want := output, (resource.CheckResponse{wantWeb, wantAPI}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	for _, v := range output {
+		source := input.Source.ForVersion(v)
+		if err := source.Validate(); err != nil {
+			t.Errorf("invalid source for version: %s", err)
+		}
+		if got, want := source.Repository, v.Repository; got != want {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+		}
+	}
+}
+
+func TestCheckSearchQueryStates(t *testing.T) {
+	merged := createTestPR(1, false)
+	merged.Repository.NameWithOwner = "itsdalmo/api"
+	closed := createTestPR(2, false)
+	closed.Repository.NameWithOwner = "itsdalmo/api"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	managers := map[string]*mocks.MockGithub{
+		"":             mocks.NewMockGithub(ctrl),
+		"itsdalmo/api": mocks.NewMockGithub(ctrl),
+	}
+	managers[""].EXPECT().SearchPullRequests(gomock.Any(), "is:merged org:itsdalmo", 1).Times(1).Return([]*resource.PullRequest{merged}, nil)
+	managers[""].EXPECT().SearchPullRequests(gomock.Any(), "is:closed is:unmerged org:itsdalmo", 1).Times(1).Return([]*resource.PullRequest{closed}, nil)
+	newGithub := func(repository string) (resource.Github, error) {
+		return managers[repository], nil
+	}
+
+	input := resource.CheckRequest{
+		Source: resource.Source{
+			SearchQuery: "org:itsdalmo",
+			AccessToken: "oauthtoken",
+			States:      []string{"merged", "CLOSED"},
+		},
+		Version: resource.NewVersion(createTestPR(5, false)),
+	}
+	output, err := resource.CheckRepositories(input, newGithub)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantMerged, wantClosed := resource.NewVersion(merged), resource.NewVersion(closed)
+	wantMerged.Repository, wantClosed.Repository = "itsdalmo/api", "itsdalmo/api"
+	if got, want := output, (resource.CheckResponse{wantClosed, wantMerged}); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestMatchesPaths(t *testing.T) {
+	cases := []struct {
+		description string
+		files       []string
+		paths       []string
+		ignorePaths []string
+		want        bool
+	}{
+		{
+			description: "matches without filters",
+			files:       []string{"README.md"},
+			want:        true,
+		},
+		{
+			description: "matches if any file matches the paths",
+			files:       []string{"README.md", "terraform/main.tf"},
+			paths:       []string{"terraform/*"},
+			want:        true,
+		},
+		{
+			description: "does not match if no file matches the paths",
+			files:       []string{"README.md", "docs/index.md"},
+			paths:       []string{"terraform/*"},
+			want:        false,
+		},
+		{
+			description: "matches if some files are not ignored",
+			files:       []string{"README.md", "terraform/main.tf"},
+			ignorePaths: []string{"*.md"},
+			want:        true,
+		},
+		{
+			description: "does not match if all files are ignored",
+			files:       []string{"README.md", "travis.yml"},
+			ignorePaths: []string{"*.md", "*.yml"},
+			want:        false,
+		},
+		{
+			description: "does not match if all files matching the paths are ignored",
+			files:       []string{"terraform/README.md"},
+			paths:       []string{"terraform/*"},
+			ignorePaths: []string{"*/*.md"},
+			want:        false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := resource.MatchesPaths(tc.files, tc.paths, tc.ignorePaths)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
 			}
 		})
 	}
@@ -17,11 +17,16 @@ func main() {
 	if err := request.Source.Validate(); err != nil {
 		log.Fatalf("invalid source configuration: %s", err)
 	}
-	github, err := resource.NewGithubClient(&request.Source)
-	if err != nil {
-		log.Fatalf("failed to create github manager: %s", err)
+	newGithub := func(repository string) (resource.Github, error) {
+		source := request.Source
+		source.Repository = repository
+		github, err := resource.NewGithubClient(&source)
+		if err != nil {
+			return nil, err
+		}
+		return github, nil
 	}
-	response, err := resource.Check(request, github)
+	response, err := resource.CheckRepositories(request, newGithub)
 	if err != nil {
 		log.Fatalf("check failed: %s", err)
 	}
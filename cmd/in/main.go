@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/itsdalmo/github-pr-resource"
 )
@@ -21,15 +22,35 @@ func main() {
 	if err := request.Source.Validate(); err != nil {
 		log.Fatalf("invalid source configuration: %s", err)
 	}
-	git, err := resource.NewGitClient(&request.Source, outputDir, os.Stderr)
-	if err != nil {
-		log.Fatalf("failed to create git client: %s", err)
-	}
-	github, err := resource.NewGithubClient(&request.Source)
+	// The version names the repository when checking multiple repositories.
+	source := request.Source.ForVersion(request.Version)
+	github, err := resource.NewGithubClient(&source)
 	if err != nil {
 		log.Fatalf("failed to create github manager: %s", err)
 	}
-	response, err := resource.Get(request, github, git, outputDir)
+
+	var response *resource.GetResponse
+	if request.Version.Batch != "" {
+		newGit := func(dir, outputDir string) (resource.Git, error) {
+			git, err := resource.NewGitClient(&request.Source, dir, os.Stderr)
+			if err != nil {
+				return nil, err
+			}
+			git.Verbose = request.Params.Verbose
+			git.OutputDir = outputDir
+			return git, nil
+		}
+		response, err = resource.GetBatch(request, github, newGit, outputDir)
+	} else {
+		var git *resource.GitClient
+		git, err = resource.NewGitClient(&request.Source, filepath.Join(outputDir, request.Params.CloneDir), os.Stderr)
+		if err != nil {
+			log.Fatalf("failed to create git client: %s", err)
+		}
+		git.Verbose = request.Params.Verbose
+		git.OutputDir = outputDir
+		response, err = resource.Get(request, github, git, outputDir)
+	}
 	if err != nil {
 		log.Fatalf("get failed: %s", err)
 	}
@@ -21,7 +21,13 @@ func main() {
 	if err := request.Source.Validate(); err != nil {
 		log.Fatalf("invalid source configuration: %s", err)
 	}
-	github, err := resource.NewGithubClient(&request.Source)
+	// The version names the repository when checking multiple repositories (or a search query).
+	version, err := resource.PutVersion(request, sourceDir)
+	if err != nil {
+		log.Fatalf("put failed: %s", err)
+	}
+	source := request.Source.ForVersion(version)
+	github, err := resource.NewGithubClient(&source)
 	if err != nil {
 		log.Fatalf("failed to create github manager: %s", err)
 	}
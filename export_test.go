@@ -0,0 +1,33 @@
+package resource
+
+import "time"
+
+// SetNow replaces the clock used by Check and returns a function to restore it.
+func SetNow(f func() time.Time) func() {
+	original := now
+	now = f
+	return func() { now = original }
+}
+
+// SetRetryDelay replaces the delay between retries in Get and returns a function to restore it.
+func SetRetryDelay(d time.Duration) func() {
+	original := retryDelay
+	retryDelay = d
+	return func() { retryDelay = original }
+}
+
+// ParseRepository exports parseRepository for testing.
+var ParseRepository = parseRepository
+
+// NetrcPassword exports netrcPassword for testing.
+var NetrcPassword = netrcPassword
+
+// GithubHost exports githubHost for testing.
+var GithubHost = githubHost
+
+// ResetVerifiedAccess forgets the repositories the access token was verified for.
+func ResetVerifiedAccess() {
+	verifiedAccess.Lock()
+	defer verifiedAccess.Unlock()
+	verifiedAccess.Repositories = make(map[string]bool)
+}
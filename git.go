@@ -1,30 +1,48 @@
 package resource
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Git interface for testing purposes.
 //go:generate mockgen -destination=mocks/mock_git.go -package=mocks github.com/itsdalmo/github-pr-resource Git
 type Git interface {
-	Init() error
-	Pull(string) error
-	Fetch(string, int) error
-	Checkout(string) error
-	Merge(string) error
-	RevParse(string) (string, error)
+	Init(context.Context) error
+	Config(context.Context, string, string) error
+	Pull(context.Context, string) error
+	Fetch(context.Context, string, int, []string) error
+	Checkout(context.Context, string, string) error
+	Merge(context.Context, string, string, string) error
+	MergeSquash(context.Context, string, string, string) error
+	RevParse(context.Context, string) (string, error)
+	MergeBase(context.Context, string, string) (string, error)
+	ConflictedFiles(context.Context) ([]string, error)
+	Diff(context.Context, string, string, io.Writer) error
+	SparseCheckout(context.Context, []string) error
+	VerifyCommit(context.Context, string) error
 }
 
 // NewGitClient ...
 func NewGitClient(source *Source, dir string, output io.Writer) (*GitClient, error) {
+	token, err := sourceAccessToken(source)
+	if err != nil {
+		return nil, err
+	}
 	return &GitClient{
-		AccessToken: source.AccessToken,
+		AccessToken: token,
+		Proxy:       source.Proxy,
+		CABundle:    source.CABundle,
 		Directory:   dir,
 		Output:      output,
 	}, nil
@@ -33,93 +51,319 @@ func NewGitClient(source *Source, dir string, output io.Writer) (*GitClient, err
 // GitClient ...
 type GitClient struct {
 	AccessToken string
+	Proxy       string
+	CABundle    string
 	Directory   string
 	Output      io.Writer
+	Verbose     bool
+	// OutputDir is the resource directory that git.log is written to, which
+	// differs from Directory with clone_dir (defaults to Directory).
+	OutputDir string
 }
 
-func (g *GitClient) command(name string, arg ...string) *exec.Cmd {
-	cmd := exec.Command(name, arg...)
+// command returns the command, which is killed if the context is done before it completes.
+func (g *GitClient) command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, arg...)
 	cmd.Dir = g.Directory
 	cmd.Stdout = g.Output
 	cmd.Stderr = g.Output
 	return cmd
 }
 
+// run the command and include the tail of its output in the error if it fails.
+// The output is also appended to the transcript when Verbose is set. ErrTimeout
+// is returned if the command was killed because the context reached its deadline.
+func (g *GitClient) run(ctx context.Context, cmd *exec.Cmd) error {
+	out := &cappedBuffer{Max: maxCapturedOutput}
+	cmd.Stdout = teeWriter(cmd.Stdout, out)
+	cmd.Stderr = teeWriter(cmd.Stderr, out)
+	err := cmd.Run()
+	transcript := g.redact(out.String())
+	if g.Verbose {
+		if logErr := g.log(cmd, transcript); logErr != nil {
+			fmt.Fprintf(g.Output, "warning: failed to write git log: %s\n", logErr)
+		}
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	if err != nil {
+		if tail := outputTail(transcript); tail != "" {
+			return fmt.Errorf("%s: %s", err, tail)
+		}
+	}
+	return err
+}
+
+// log appends the command and its output to the transcript in .git/resource/git.log
+// of the output directory. Commands that run before the repository is initialized are not logged.
+func (g *GitClient) log(cmd *exec.Cmd, output string) error {
+	if _, err := os.Stat(filepath.Join(g.Directory, ".git")); err != nil {
+		return nil
+	}
+	dir := g.OutputDir
+	if dir == "" {
+		dir = g.Directory
+	}
+	dir = filepath.Join(dir, ".git", "resource")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "git.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "$ %s\n%s", g.redact(strings.Join(cmd.Args, " ")), output); err != nil {
+		return err
+	}
+	return nil
+}
+
+// redact the access token, which is part of the remote URL for pull and fetch
+// (where it is escaped if it contains special characters).
+func (g *GitClient) redact(s string) string {
+	if g.AccessToken == "" {
+		return s
+	}
+	s = strings.Replace(s, g.AccessToken, "[redacted]", -1)
+	if escaped := url.UserPassword("", g.AccessToken).String(); escaped != ":"+g.AccessToken {
+		s = strings.Replace(s, strings.TrimPrefix(escaped, ":"), "[redacted]", -1)
+	}
+	return s
+}
+
+// outputTailLines is the number of lines of git output included in errors.
+const outputTailLines = 10
+
+func outputTail(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > outputTailLines {
+		lines = lines[len(lines)-outputTailLines:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func teeWriter(w io.Writer, capture io.Writer) io.Writer {
+	if w == nil {
+		return capture
+	}
+	return io.MultiWriter(w, capture)
+}
+
+// maxCapturedOutput is the most output of a single git command that is kept in
+// memory (for errors and the transcript). Large outputs, like a diff, are streamed.
+const maxCapturedOutput = 1 << 20
+
+// cappedBuffer keeps (at most) the first Max bytes written to it. It is safe
+// for concurrent writes, since stdout and stderr are copied in separate goroutines.
+type cappedBuffer struct {
+	mu        sync.Mutex
+	buffer    bytes.Buffer
+	Max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n := b.Max - b.buffer.Len(); n < len(p) {
+		b.truncated = true
+		if n > 0 {
+			b.buffer.Write(p[:n])
+		}
+		return len(p), nil
+	}
+	return b.buffer.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buffer.String() + "\n[output truncated]\n"
+	}
+	return b.buffer.String()
+}
+
 // Init ...
-func (g *GitClient) Init() error {
-	if err := g.command("git", "init").Run(); err != nil {
+func (g *GitClient) Init(ctx context.Context) error {
+	if err := g.run(ctx, g.command(ctx, "git", "init")); err != nil {
 		return fmt.Errorf("init failed: %s", err)
 	}
-	if err := g.command("git", "config", "user.name", "concourse-ci").Run(); err != nil {
-		return fmt.Errorf("failed to configure git user: %s", err)
+	if g.Proxy != "" {
+		if err := g.run(ctx, g.command(ctx, "git", "config", "http.proxy", g.Proxy)); err != nil {
+			return fmt.Errorf("failed to configure git proxy: %s", err)
+		}
+	}
+	if g.CABundle != "" {
+		// An inline bundle is written to the git directory, where it is not part of the checkout.
+		path := g.CABundle
+		if strings.Contains(path, "-----BEGIN") {
+			path = filepath.Join(g.Directory, ".git", "ca-bundle.pem")
+			if err := ioutil.WriteFile(path, []byte(g.CABundle), 0644); err != nil {
+				return fmt.Errorf("failed to write ca bundle: %s", err)
+			}
+		}
+		if err := g.run(ctx, g.command(ctx, "git", "config", "http.sslCAInfo", path)); err != nil {
+			return fmt.Errorf("failed to configure git ca bundle: %s", err)
+		}
 	}
-	if err := g.command("git", "config", "user.email", "concourse@local").Run(); err != nil {
-		return fmt.Errorf("failed to configure git email: %s", err)
+	return nil
+}
+
+// Config sets a git config entry for the local repository.
+func (g *GitClient) Config(ctx context.Context, key, value string) error {
+	if err := g.run(ctx, g.command(ctx, "git", "config", "--local", key, value)); err != nil {
+		return fmt.Errorf("failed to set git config %s: %s", key, err)
 	}
 	return nil
 }
 
 // Pull ...
-func (g *GitClient) Pull(uri string) error {
+func (g *GitClient) Pull(ctx context.Context, uri string) error {
 	endpoint, err := g.Endpoint(uri)
 	if err != nil {
 		return err
 	}
-	cmd := g.command("git", "pull", endpoint+".git")
+	if !strings.HasSuffix(endpoint, ".git") {
+		endpoint += ".git"
+	}
+	cmd := g.command(ctx, "git", "pull", endpoint)
 
-	// Discard output to have zero chance of logging the access token.
+	// Keep the output out of the build log, as it can contain the endpoint (with the
+	// access token). The output captured for errors and git.log is redacted by run.
 	cmd.Stdout = ioutil.Discard
 	cmd.Stderr = ioutil.Discard
 
-	if err := cmd.Run(); err != nil {
+	if err := g.run(ctx, cmd); err != nil {
 		return fmt.Errorf("pull failed: %s", err)
 	}
 	return nil
 }
 
-// Fetch ...
-func (g *GitClient) Fetch(uri string, prNumber int) error {
+// Fetch the head of the pull request, and any additional refspecs.
+func (g *GitClient) Fetch(ctx context.Context, uri string, prNumber int, refspecs []string) error {
 	endpoint, err := g.Endpoint(uri)
 	if err != nil {
 		return err
 	}
-	cmd := g.command("git", "fetch", endpoint, fmt.Sprintf("pull/%s/head", strconv.Itoa(prNumber)))
+	args := append([]string{"fetch", endpoint, fmt.Sprintf("pull/%s/head", strconv.Itoa(prNumber))}, refspecs...)
+	cmd := g.command(ctx, "git", args...)
 
-	// Discard output to have zero chance of logging the access token.
+	// Keep the output out of the build log, as it can contain the endpoint (with the
+	// access token). The output captured for errors and git.log is redacted by run.
 	cmd.Stdout = ioutil.Discard
 	cmd.Stderr = ioutil.Discard
 
-	if err := cmd.Run(); err != nil {
+	if err := g.run(ctx, cmd); err != nil {
 		return fmt.Errorf("fetch failed: %s", err)
 	}
 	return nil
 }
 
-// Checkout ...
-func (g *GitClient) Checkout(name string) error {
-	if err := g.command("git", "checkout", "-b", name).Run(); err != nil {
+// Checkout a new branch starting at the given SHA.
+func (g *GitClient) Checkout(ctx context.Context, name, sha string) error {
+	if err := g.run(ctx, g.command(ctx, "git", "checkout", "-b", name, sha)); err != nil {
 		return fmt.Errorf("failed to checkout new branch: %s", err)
 	}
 	return nil
 }
 
 // Merge ...
-func (g *GitClient) Merge(sha string) error {
-	if err := g.command("git", "merge", sha, "--no-stat").Run(); err != nil {
+func (g *GitClient) Merge(ctx context.Context, sha, strategyOption, message string) error {
+	args := []string{"merge", sha, "--no-stat"}
+	if message != "" {
+		args = append(args, "-m", message)
+	}
+	if err := g.run(ctx, g.command(ctx, "git", mergeArgs(args, strategyOption)...)); err != nil {
 		return fmt.Errorf("merge failed: %s", err)
 	}
 	return nil
 }
 
+// MergeSquash commits the changes up to the given SHA as a single commit on the current branch.
+// The commit message defaults to "Squashed commit of <sha>".
+func (g *GitClient) MergeSquash(ctx context.Context, sha, strategyOption, message string) error {
+	if err := g.run(ctx, g.command(ctx, "git", mergeArgs([]string{"merge", "--squash", sha, "--no-stat"}, strategyOption)...)); err != nil {
+		return fmt.Errorf("squash merge failed: %s", err)
+	}
+	if message == "" {
+		message = fmt.Sprintf("Squashed commit of %s", sha)
+	}
+	if err := g.run(ctx, g.command(ctx, "git", "commit", "-m", message)); err != nil {
+		return fmt.Errorf("failed to commit squash merge: %s", err)
+	}
+	return nil
+}
+
+// mergeArgs adds the strategy option (e.g. theirs for -X theirs), if any, to the merge arguments.
+func mergeArgs(args []string, strategyOption string) []string {
+	if strategyOption == "" {
+		return args
+	}
+	return append(args, "-X", strategyOption)
+}
+
 // RevParse retrieves the SHA of the given branch.
-func (g *GitClient) RevParse(branch string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--verify", branch)
-	cmd.Dir = g.Directory
-	sha, err := cmd.CombinedOutput()
-	if err != nil {
+func (g *GitClient) RevParse(ctx context.Context, branch string) (string, error) {
+	var sha bytes.Buffer
+	cmd := g.command(ctx, "git", "rev-parse", "--verify", branch)
+	cmd.Stdout = &sha
+	cmd.Stderr = &sha
+	if err := g.run(ctx, cmd); err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(sha)), nil
+	return strings.TrimSpace(sha.String()), nil
+}
+
+// MergeBase returns the SHA of the best common ancestor of the two commits.
+func (g *GitClient) MergeBase(ctx context.Context, a, b string) (string, error) {
+	var sha bytes.Buffer
+	cmd := g.command(ctx, "git", "merge-base", a, b)
+	cmd.Stdout = &sha
+	if err := g.run(ctx, cmd); err != nil {
+		return "", fmt.Errorf("merge-base failed: %s", err)
+	}
+	return strings.TrimSpace(sha.String()), nil
+}
+
+// Diff writes the unified diff of the changes on head since it diverged from base.
+func (g *GitClient) Diff(ctx context.Context, base, head string, w io.Writer) error {
+	cmd := g.command(ctx, "git", "diff", base+"..."+head)
+	cmd.Stdout = w
+	if err := g.run(ctx, cmd); err != nil {
+		return fmt.Errorf("diff failed: %s", err)
+	}
+	return nil
+}
+
+// ConflictedFiles lists the files with unresolved merge conflicts.
+func (g *GitClient) ConflictedFiles(ctx context.Context) ([]string, error) {
+	var out bytes.Buffer
+	cmd := g.command(ctx, "git", "diff", "--name-only", "--diff-filter=U")
+	cmd.Stdout = &out
+	if err := g.run(ctx, cmd); err != nil {
+		return nil, fmt.Errorf("failed to list conflicted files: %s", err)
+	}
+	return strings.Fields(out.String()), nil
+}
+
+// SparseCheckout restricts the working tree to the paths (gitignore style patterns).
+func (g *GitClient) SparseCheckout(ctx context.Context, paths []string) error {
+	args := append([]string{"sparse-checkout", "set", "--no-cone"}, paths...)
+	if err := g.run(ctx, g.command(ctx, "git", args...)); err != nil {
+		return fmt.Errorf("failed to configure sparse checkout: %s", err)
+	}
+	return nil
+}
+
+// VerifyCommit returns an error if the commit does not exist in the repository.
+func (g *GitClient) VerifyCommit(ctx context.Context, sha string) error {
+	if err := g.run(ctx, g.command(ctx, "git", "cat-file", "-e", sha+"^{commit}")); err != nil {
+		return fmt.Errorf("commit %s is missing: %s", sha, err)
+	}
+	return nil
 }
 
 // Endpoint takes an uri and produces an endpoint with the login information baked in.
@@ -0,0 +1,258 @@
+package resource_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itsdalmo/github-pr-resource"
+)
+
+func TestGitClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "github-pr-resource")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	git, err := resource.NewGitClient(&resource.Source{AccessToken: "oauthtoken"}, dir, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to create git client: %s", err)
+	}
+	if err := exec.Command("git", "init", dir).Run(); err != nil {
+		t.Fatalf("failed to init repository: %s", err)
+	}
+
+	// The command is killed when the context reaches its deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = git.Pull(ctx, server.URL+"/itsdalmo/test-repository")
+	if err == nil || !strings.Contains(err.Error(), resource.ErrTimeout.Error()) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, resource.ErrTimeout)
+	}
+}
+
+func TestGitClientErrorIncludesOutput(t *testing.T) {
+	tests := []struct {
+		description string
+		verbose     bool
+	}{
+		{
+			description: "includes the output in the error",
+			verbose:     false,
+		},
+		{
+			description: "writes the output to the log when verbose",
+			verbose:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "github-pr-resource")
+			if err != nil {
+				t.Fatalf("failed to create temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			git, err := resource.NewGitClient(&resource.Source{AccessToken: "oauthtoken"}, dir, ioutil.Discard)
+			if err != nil {
+				t.Fatalf("failed to create git client: %s", err)
+			}
+			git.Verbose = tc.verbose
+			if err := git.Init(context.Background()); err != nil {
+				t.Fatalf("failed to init repository: %s", err)
+			}
+
+			err = git.Checkout(context.Background(), "pr", "missing-branch")
+			if err == nil || !strings.Contains(err.Error(), "missing-branch") {
+				t.Fatalf("expected the git output in the error, got: %v", err)
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, ".git", "resource", "git.log"))
+			if !tc.verbose {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected no log file, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read log file: %s", err)
+			}
+			if got := string(b); !strings.Contains(got, "$ git checkout") || !strings.Contains(got, "missing-branch") {
+				t.Errorf("unexpected log file contents:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestGitClientLogOutputDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "github-pr-resource")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The repository is cloned into a subdirectory of the resource (clone_dir).
+	repo := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(repo, os.ModePerm); err != nil {
+		t.Fatalf("failed to create clone directory: %s", err)
+	}
+	git, err := resource.NewGitClient(&resource.Source{AccessToken: "oauthtoken"}, repo, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to create git client: %s", err)
+	}
+	git.Verbose = true
+	git.OutputDir = dir
+	if err := git.Init(context.Background()); err != nil {
+		t.Fatalf("failed to init repository: %s", err)
+	}
+	if err := git.Checkout(context.Background(), "pr", "missing-branch"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, ".git", "resource", "git.log"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %s", err)
+	}
+	if got := string(b); !strings.Contains(got, "$ git checkout") {
+		t.Errorf("unexpected log file contents:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git", "resource")); !os.IsNotExist(err) {
+		t.Errorf("expected no log in the clone directory")
+	}
+}
+
+func TestGitClientMergeStrategyOption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "github-pr-resource")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a branch that conflicts with master on the lockfile.
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "lockfile"), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+	run("init")
+	run("config", "user.name", "test")
+	run("config", "user.email", "test@local")
+	write("base\n")
+	run("add", "lockfile")
+	run("commit", "-m", "base")
+	run("checkout", "-b", "pr")
+	write("pr\n")
+	run("commit", "-am", "pr")
+	pr := run("rev-parse", "HEAD")
+	run("checkout", "-")
+	write("master\n")
+	run("commit", "-am", "master")
+
+	git, err := resource.NewGitClient(&resource.Source{AccessToken: "oauthtoken"}, dir, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to create git client: %s", err)
+	}
+	if err := git.Merge(context.Background(), pr, "theirs", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := readTestFile(t, filepath.Join(dir, "lockfile")), "pr\n"; got != want {
+		t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, want)
+	}
+}
+
+func TestGitClientMergeBase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "github-pr-resource")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a branch that diverged from master after the base commit.
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init")
+	run("config", "user.name", "test")
+	run("config", "user.email", "test@local")
+	run("commit", "--allow-empty", "-m", "base")
+	base := run("rev-parse", "HEAD")
+	run("checkout", "-b", "pr")
+	run("commit", "--allow-empty", "-m", "pr")
+	pr := run("rev-parse", "HEAD")
+	run("checkout", "-")
+	run("commit", "--allow-empty", "-m", "master")
+	master := run("rev-parse", "HEAD")
+
+	git, err := resource.NewGitClient(&resource.Source{AccessToken: "oauthtoken"}, dir, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to create git client: %s", err)
+	}
+	got, err := git.MergeBase(context.Background(), master, pr)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != base {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, base)
+	}
+}
+
+func TestGitClientCABundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "github-pr-resource")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	bundle := "-----BEGIN CERTIFICATE-----\nbm9wZQ==\n-----END CERTIFICATE-----\n"
+	git, err := resource.NewGitClient(&resource.Source{AccessToken: "oauthtoken", CABundle: bundle}, dir, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to create git client: %s", err)
+	}
+	if err := git.Init(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cmd := exec.Command("git", "config", "http.sslCAInfo")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to read git config: %s", err)
+	}
+	path := strings.TrimSpace(string(out))
+	if want := filepath.Join(dir, ".git", "ca-bundle.pem"); path != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", path, want)
+	}
+	if got := readTestFile(t, path); got != bundle {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, bundle)
+	}
+}
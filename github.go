@@ -1,13 +1,24 @@
 package resource
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/shurcooL/githubv4"
@@ -17,11 +28,27 @@ import (
 // Github for testing purposes.
 //go:generate mockgen -destination=mocks/mock_github.go -package=mocks github.com/itsdalmo/github-pr-resource Github
 type Github interface {
-	ListOpenPullRequests() ([]*PullRequest, error)
-	ListModifiedFiles(int) ([]string, error)
-	PostComment(string, string) error
-	GetPullRequest(string, string) (*PullRequest, error)
-	UpdateCommitStatus(string, string, string) error
+	ListPullRequests(context.Context, []githubv4.PullRequestState, int, *githubv4.IssueOrder) ([]*PullRequest, error)
+	ListModifiedFiles(context.Context, int) ([]string, error)
+	ListModifiedFilesPage(context.Context, int, int) ([]string, int, error)
+	PostComment(context.Context, string, string) error
+	GetPullRequest(context.Context, string, string) (*PullRequest, error)
+	GetPullRequestByCommit(context.Context, string) (*PullRequest, error)
+	AddLabels(context.Context, int, []string) error
+	RemoveLabels(context.Context, int, []string) error
+	SubmitReview(context.Context, int, string, string, string) error
+	MergePullRequest(context.Context, int, string, string, string, string) error
+	DeleteBranch(context.Context, string) error
+	UpdateCommitStatus(context.Context, string, string, string) error
+	GetRepository(context.Context) (*RepositoryObject, error)
+	SearchPullRequests(context.Context, string, int) ([]*PullRequest, error)
+	GetRequiredStatusContexts(context.Context, string) ([]string, error)
+	GetStatusContexts(context.Context, string) (map[string]string, error)
+	LatestRelease(context.Context) (string, error)
+	ListParticipants(context.Context, int) ([]string, error)
+	ListCommitMessages(context.Context, int) ([]string, error)
+	ListLinkedIssues(context.Context, int) ([]LinkedIssue, error)
+	GetFileContent(context.Context, string, string) (string, error)
 }
 
 // GithubClient for handling requests to the Github V3 and V4 APIs.
@@ -30,22 +57,78 @@ type GithubClient struct {
 	V4         *githubv4.Client
 	Repository string
 	Owner      string
+
+	log *logger
 }
 
 // NewGithubClient ...
 func NewGithubClient(s *Source) (*GithubClient, error) {
-	owner, repository, err := parseRepository(s.Repository)
+	// A client without a repository can only search for pull requests (see search_query).
+	var owner, repository string
+	if s.Repository != "" || s.SearchQuery == "" {
+		var err error
+		owner, repository, err = parseRepository(s.Repository)
+		if err != nil {
+			return nil, err
+		}
+	}
+	token, err := sourceAccessToken(s)
 	if err != nil {
 		return nil, err
 	}
+	v3Endpoint, v4Endpoint := s.V3Endpoint, s.V4Endpoint
+	if v3Endpoint == "" && v4Endpoint == "" {
+		v3Endpoint, v4Endpoint = enterpriseEndpoints(s.Repository)
+	}
+
+	// Honor HTTP_PROXY/HTTPS_PROXY/NO_PROXY unless a proxy is configured explicitly.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if s.Proxy != "" {
+		proxy, err := url.Parse(s.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if s.CABundle != "" {
+		pool, err := caCertPool(s.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	ctx := context.WithValue(context.TODO(), oauth2.HTTPClient, &http.Client{Transport: transport})
 
-	client := oauth2.NewClient(context.TODO(), oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: s.AccessToken},
+	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
 	))
 
+	// Apply defaults to a copy to leave the caller's source untouched.
+	defaults := *s
+	defaults.ApplyDefaults()
+	log := &logger{Format: defaults.LogFormat}
+	client.Transport = &apiVersionTransport{
+		Version: defaults.APIVersion,
+		Base:    client.Transport,
+	}
+	if defaults.CacheDir != "" {
+		client.Transport = &cacheTransport{
+			Dir:   defaults.CacheDir,
+			Token: token,
+			Log:   log,
+			Base:  client.Transport,
+		}
+	}
+	client.Transport = &rateLimitTransport{
+		Threshold: defaults.RateLimitWarnThreshold,
+		Log:       log,
+		Base:      client.Transport,
+	}
+
 	var v3 *github.Client
-	if s.V3Endpoint != "" {
-		endpoint, err := url.Parse(s.V3Endpoint)
+	if v3Endpoint != "" {
+		endpoint, err := url.Parse(v3Endpoint)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse v3 endpoint: %s", err)
 		}
@@ -58,8 +141,8 @@ func NewGithubClient(s *Source) (*GithubClient, error) {
 	}
 
 	var v4 *githubv4.Client
-	if s.V4Endpoint != "" {
-		endpoint, err := url.Parse(s.V4Endpoint)
+	if v4Endpoint != "" {
+		endpoint, err := url.Parse(v4Endpoint)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse v4 endpoint: %s", err)
 		}
@@ -71,36 +154,84 @@ func NewGithubClient(s *Source) (*GithubClient, error) {
 		v4 = githubv4.NewClient(client)
 	}
 
-	return &GithubClient{
+	m := &GithubClient{
 		V3:         v3,
 		V4:         v4,
 		Owner:      owner,
 		Repository: repository,
-	}, nil
+		log:        log,
+	}
+	if verify, _ := strconv.ParseBool(s.VerifyAccess); verify && repository != "" {
+		ctx, cancel, err := s.timeoutContext()
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		if err := m.verifyAccess(ctx); err != nil {
+			return nil, classifyError(ctx, err)
+		}
+	}
+	return m, nil
+}
+
+// verifiedAccess records the repositories the access token was verified for,
+// so the pre-flight check runs once per process.
+var verifiedAccess = struct {
+	sync.Mutex
+	Repositories map[string]bool
+}{Repositories: make(map[string]bool)}
+
+// verifyAccess checks that the access token can read the repository, and
+// returns an error explaining which access is missing if it cannot.
+func (m *GithubClient) verifyAccess(ctx context.Context) error {
+	name := m.Owner + "/" + m.Repository
+	verifiedAccess.Lock()
+	defer verifiedAccess.Unlock()
+	if verifiedAccess.Repositories[name] {
+		return nil
+	}
+
+	var query struct {
+		Viewer struct {
+			Login string
+		}
+		Repository struct {
+			ViewerPermission string
+		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
+	}
+	vars := map[string]interface{}{
+		"repositoryOwner": githubv4.String(m.Owner),
+		"repositoryName":  githubv4.String(m.Repository),
+	}
+	if err := m.V4.Query(ctx, &query, vars); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "401 Unauthorized"):
+			return &classifiedError{Kind: ErrAuth, Err: errors.New("access token was rejected by Github (it may be expired or revoked)")}
+		case strings.HasPrefix(err.Error(), "Could not resolve to a Repository"),
+			strings.HasPrefix(err.Error(), "Resource not accessible"):
+			return fmt.Errorf("access token cannot read %s: grant it read access to the contents, pull requests and commit statuses of the repository (or the repo scope for a classic token)", name)
+		}
+		return fmt.Errorf("failed to verify access to %s: %s", name, err)
+	}
+	verifiedAccess.Repositories[name] = true
+	return nil
 }
 
-// ListOpenPullRequests gets the last commit on all open pull requests.
-func (m *GithubClient) ListOpenPullRequests() ([]*PullRequest, error) {
+// ListPullRequests gets the last commits on all pull requests with the given states.
+// A PullRequest is returned for each of the (at most 100) last commits. The
+// order is left to Github if prOrder is nil.
+func (m *GithubClient) ListPullRequests(ctx context.Context, prStates []githubv4.PullRequestState, commitsLast int, prOrder *githubv4.IssueOrder) ([]*PullRequest, error) {
 	var query struct {
 		Repository struct {
 			PullRequests struct {
 				Edges []struct {
-					Node struct {
-						PullRequestObject
-						Commits struct {
-							Edges []struct {
-								Node struct {
-									Commit CommitObject
-								}
-							}
-						} `graphql:"commits(last:$commitsLast)"`
-					}
+					Node pullRequestNode
 				}
 				PageInfo struct {
 					EndCursor   githubv4.String
 					HasNextPage bool
 				}
-			} `graphql:"pullRequests(first:$prFirst,states:$prStates,after:$prCursor)"`
+			} `graphql:"pullRequests(first:$prFirst,states:$prStates,after:$prCursor,orderBy:$prOrder)"`
 		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
 	}
 
@@ -108,23 +239,19 @@ func (m *GithubClient) ListOpenPullRequests() ([]*PullRequest, error) {
 		"repositoryOwner": githubv4.String(m.Owner),
 		"repositoryName":  githubv4.String(m.Repository),
 		"prFirst":         githubv4.Int(100),
-		"prStates":        []githubv4.PullRequestState{githubv4.PullRequestStateOpen},
+		"prStates":        prStates,
 		"prCursor":        (*githubv4.String)(nil),
-		"commitsLast":     githubv4.Int(1),
+		"prOrder":         prOrder,
+		"commitsLast":     githubv4.Int(commitsLast),
 	}
 
 	var response []*PullRequest
 	for {
-		if err := m.V4.Query(context.TODO(), &query, vars); err != nil {
-			return nil, err
+		if err := m.V4.Query(ctx, &query, vars); err != nil {
+			return nil, m.repositoryError(err)
 		}
 		for _, p := range query.Repository.PullRequests.Edges {
-			for _, c := range p.Node.Commits.Edges {
-				response = append(response, &PullRequest{
-					PullRequestObject: p.Node.PullRequestObject,
-					Tip:               c.Node.Commit,
-				})
-			}
+			response = append(response, p.Node.PullRequests()...)
 		}
 		if !query.Repository.PullRequests.PageInfo.HasNextPage {
 			break
@@ -134,37 +261,295 @@ func (m *GithubClient) ListOpenPullRequests() ([]*PullRequest, error) {
 	return response, nil
 }
 
-// ListModifiedFiles in a pull request (not supported by V4 API).
-func (m *GithubClient) ListModifiedFiles(prNumber int) ([]string, error) {
-	var files []string
+// SearchPullRequests gets the last commits on all pull requests matching the
+// search query (across repositories), like ListPullRequests. The repository of
+// each pull request is in Repository.NameWithOwner.
+func (m *GithubClient) SearchPullRequests(ctx context.Context, searchQuery string, commitsLast int) ([]*PullRequest, error) {
+	var query struct {
+		Search struct {
+			Nodes []struct {
+				PullRequest pullRequestNode `graphql:"... on PullRequest"`
+			}
+			PageInfo struct {
+				EndCursor   githubv4.String
+				HasNextPage bool
+			}
+		} `graphql:"search(query:$searchQuery,type:ISSUE,first:$searchFirst,after:$searchCursor)"`
+	}
 
-	opt := &github.ListOptions{
-		PerPage: 100,
+	vars := map[string]interface{}{
+		"searchQuery":  githubv4.String("is:pr " + searchQuery),
+		"searchFirst":  githubv4.Int(100),
+		"searchCursor": (*githubv4.String)(nil),
+		"commitsLast":  githubv4.Int(commitsLast),
 	}
+
+	var response []*PullRequest
 	for {
-		result, response, err := m.V3.PullRequests.ListFiles(
-			context.TODO(),
-			m.Owner,
-			m.Repository,
-			prNumber,
-			opt,
-		)
-		if err != nil {
+		if err := m.V4.Query(ctx, &query, vars); err != nil {
 			return nil, err
 		}
-		for _, f := range result {
-			files = append(files, *f.Filename)
+		for _, n := range query.Search.Nodes {
+			response = append(response, n.PullRequest.PullRequests()...)
 		}
-		if response.NextPage == 0 {
+		if !query.Search.PageInfo.HasNextPage {
 			break
 		}
-		opt.Page = response.NextPage
+		vars["searchCursor"] = query.Search.PageInfo.EndCursor
+	}
+	return response, nil
+}
+
+// pullRequestNode is the GraphQL pull request node (with its last commits)
+// used when listing or searching for pull requests.
+type pullRequestNode struct {
+	PullRequestObject
+	Commits       lastCommits    `graphql:"commits(last:$commitsLast)"`
+	TimelineItems reopenedEvents `graphql:"timelineItems(last:1,itemTypes:[REOPENED_EVENT])"`
+	Labels        labelNames     `graphql:"labels(first:100)"`
+}
+
+// PullRequests returns a PullRequest for each of the commits in the node.
+func (n pullRequestNode) PullRequests() []*PullRequest {
+	var pulls []*PullRequest
+	for _, c := range n.Commits.Edges {
+		pulls = append(pulls, &PullRequest{
+			PullRequestObject: n.PullRequestObject,
+			Tip:               c.Node.Commit,
+			ReopenedAt:        n.TimelineItems.ReopenedAt(),
+			Labels:            n.Labels.Names(),
+		})
+	}
+	return pulls
+}
+
+// lastCommits is the GraphQL connection of the last commits of a pull request.
+type lastCommits struct {
+	Edges []struct {
+		Node struct {
+			Commit CommitObject
+		}
+	}
+}
+
+// reopenedEvents is the GraphQL connection of the last reopened event of a pull request.
+type reopenedEvents struct {
+	Nodes []struct {
+		ReopenedEvent struct {
+			CreatedAt githubv4.DateTime
+		} `graphql:"... on ReopenedEvent"`
+	}
+}
+
+// ReopenedAt returns the time the pull request was last reopened (if ever).
+func (e reopenedEvents) ReopenedAt() githubv4.DateTime {
+	var reopened githubv4.DateTime
+	for _, n := range e.Nodes {
+		reopened = n.ReopenedEvent.CreatedAt
+	}
+	return reopened
+}
+
+// labelNames is the GraphQL connection of the labels of a pull request.
+type labelNames struct {
+	Nodes []struct {
+		Name string
+	}
+}
+
+// Names returns the names of the labels.
+func (l labelNames) Names() []string {
+	var names []string
+	for _, n := range l.Nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// GetRepository returns the repository, or a RepositoryUnavailableError if it
+// does not exist (or cannot be read with the access token).
+func (m *GithubClient) GetRepository(ctx context.Context) (*RepositoryObject, error) {
+	var query struct {
+		Repository RepositoryObject `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
+	}
+
+	vars := map[string]interface{}{
+		"repositoryOwner": githubv4.String(m.Owner),
+		"repositoryName":  githubv4.String(m.Repository),
+	}
+	if err := m.V4.Query(ctx, &query, vars); err != nil {
+		return nil, m.repositoryError(err)
+	}
+	return &query.Repository, nil
+}
+
+// RepositoryUnavailableError is returned when the repository does not exist
+// (or cannot be read with the access token), or is archived.
+type RepositoryUnavailableError struct {
+	Repository string
+	Archived   bool
+}
+
+func (e *RepositoryUnavailableError) Error() string {
+	if e.Archived {
+		return fmt.Sprintf("repository %s is archived", e.Repository)
+	}
+	return fmt.Sprintf("repository %s was not found (or is not accessible with the access token)", e.Repository)
+}
+
+// Is returns true for ErrRepoNotFound, unless the repository is archived.
+func (e *RepositoryUnavailableError) Is(target error) bool {
+	return target == ErrRepoNotFound && !e.Archived
+}
+
+// classifiedError is an error from the Github APIs classified as ErrAuth or ErrRateLimited.
+type classifiedError struct {
+	Kind error
+	Err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.Err.Error()
+}
+
+// Is returns true for the classification of the error.
+func (e *classifiedError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error.
+func (e *classifiedError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError classifies errors caused by the timeout (of the context) or the
+// access token (rejected or rate limited) by either API, and returns other errors
+// as is. The V4 API reports these as messages rather than typed errors.
+func classifyError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrAuth) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: %s", ErrTimeout, err)
+	}
+	var rateLimit *github.RateLimitError
+	var abuseRateLimit *github.AbuseRateLimitError
+	var response *github.ErrorResponse
+	switch {
+	case errors.As(err, &rateLimit), errors.As(err, &abuseRateLimit),
+		strings.Contains(err.Error(), "API rate limit exceeded"),
+		strings.Contains(err.Error(), "429 Too Many Requests"):
+		return &classifiedError{Kind: ErrRateLimited, Err: err}
+	case errors.As(err, &response) && response.Response != nil && response.Response.StatusCode == http.StatusUnauthorized,
+		strings.Contains(err.Error(), "401 Unauthorized"):
+		return &classifiedError{Kind: ErrAuth, Err: err}
+	}
+	return err
+}
+
+// repositoryError returns a RepositoryUnavailableError if the V4 API could not
+// resolve the repository, and the error as is otherwise.
+func (m *GithubClient) repositoryError(err error) error {
+	if strings.HasPrefix(err.Error(), "Could not resolve to a Repository") {
+		return &RepositoryUnavailableError{Repository: m.Owner + "/" + m.Repository}
+	}
+	return err
+}
+
+// closingIssues is the GraphQL connection of issues that will be closed by a pull request.
+type closingIssues struct {
+	Nodes []struct {
+		Number int
+		Labels struct {
+			Nodes []struct {
+				Name string
+			}
+		} `graphql:"labels(first:100)"`
+	}
+}
+
+// LinkedIssues returns the issues (and their labels) in the connection.
+func (c closingIssues) LinkedIssues() []LinkedIssue {
+	var issues []LinkedIssue
+	for _, i := range c.Nodes {
+		issue := LinkedIssue{Number: i.Number}
+		for _, l := range i.Labels.Nodes {
+			issue.Labels = append(issue.Labels, l.Name)
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// ListLinkedIssues returns the issues (and their labels) that will be closed by a pull request.
+func (m *GithubClient) ListLinkedIssues(ctx context.Context, prNumber int) ([]LinkedIssue, error) {
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				ClosingIssuesReferences closingIssues `graphql:"closingIssuesReferences(first:25)"`
+			} `graphql:"pullRequest(number:$prNumber)"`
+		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
+	}
+
+	vars := map[string]interface{}{
+		"repositoryOwner": githubv4.String(m.Owner),
+		"repositoryName":  githubv4.String(m.Repository),
+		"prNumber":        githubv4.Int(prNumber),
+	}
+	if err := m.V4.Query(ctx, &query, vars); err != nil {
+		return nil, err
+	}
+	return query.Repository.PullRequest.ClosingIssuesReferences.LinkedIssues(), nil
+}
+
+// ListModifiedFiles in a pull request (not supported by V4 API).
+func (m *GithubClient) ListModifiedFiles(ctx context.Context, prNumber int) ([]string, error) {
+	var files []string
+	for page := 1; page != 0; {
+		result, next, err := m.ListModifiedFilesPage(ctx, prNumber, page)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, result...)
+		page = next
 	}
 	return files, nil
 }
 
+// ListModifiedFilesPage returns a single page of modified files in a pull request,
+// together with the number of the next page (0 when there are no more pages).
+func (m *GithubClient) ListModifiedFilesPage(ctx context.Context, prNumber, page int) ([]string, int, error) {
+	var files []string
+
+	opt := &github.ListOptions{
+		PerPage: 100,
+		Page:    page,
+	}
+	result, response, err := m.V3.PullRequests.ListFiles(
+		ctx,
+		m.Owner,
+		m.Repository,
+		prNumber,
+		opt,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, f := range result {
+		files = append(files, *f.Filename)
+	}
+	if n := (page-1)*opt.PerPage + len(files); response.NextPage == 0 && n >= MaxModifiedFiles {
+		m.log.Warnf(map[string]interface{}{"pr": prNumber, "files": n},
+			"pull request #%d changes more files than the Github API lists (%d), paths are only matched against those", prNumber, MaxModifiedFiles)
+	}
+	return files, response.NextPage, nil
+}
+
+// MaxModifiedFiles is the number of files in a pull request listed by the Github API.
+const MaxModifiedFiles = 3000
+
 // PostComment to a pull request or issue.
-func (m *GithubClient) PostComment(objectID, comment string) error {
+func (m *GithubClient) PostComment(ctx context.Context, objectID, comment string) error {
 	var mutation struct {
 		AddComment struct {
 			Subject struct {
@@ -176,12 +561,13 @@ func (m *GithubClient) PostComment(objectID, comment string) error {
 		SubjectID: objectID,
 		Body:      githubv4.String(comment),
 	}
-	err := m.V4.Mutate(context.TODO(), &mutation, input, nil)
+	err := m.V4.Mutate(ctx, &mutation, input, nil)
 	return err
 }
 
-// GetPullRequest ...
-func (m *GithubClient) GetPullRequest(prNumber, commitRef string) (*PullRequest, error) {
+// GetPullRequest returns the pull request with the given commit as the tip,
+// or the last commit of the pull request if commitRef is empty.
+func (m *GithubClient) GetPullRequest(ctx context.Context, prNumber, commitRef string) (*PullRequest, error) {
 	pr, err := strconv.Atoi(prNumber)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert pull request number to int: %s", err)
@@ -189,16 +575,10 @@ func (m *GithubClient) GetPullRequest(prNumber, commitRef string) (*PullRequest,
 
 	var query struct {
 		Repository struct {
-			PullRequest struct {
-				PullRequestObject
-				Commits struct {
-					Edges []struct {
-						Node struct {
-							Commit CommitObject
-						}
-					}
-				} `graphql:"commits(last:$commitsLast)"`
-			} `graphql:"pullRequest(number:$prNumber)"`
+			PullRequest  pullRequestNode `graphql:"pullRequest(number:$prNumber)"`
+			LinkedIssues struct {
+				ClosingIssuesReferences closingIssues `graphql:"closingIssuesReferences(first:25)"`
+			} `graphql:"linkedIssues: pullRequest(number:$prNumber)"`
 		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
 	}
 
@@ -210,33 +590,56 @@ func (m *GithubClient) GetPullRequest(prNumber, commitRef string) (*PullRequest,
 	}
 
 	// TODO: Pagination - in case someone pushes > 100 commits before the build has time to start :p
-	if err := m.V4.Query(context.TODO(), &query, vars); err != nil {
+	if err := m.V4.Query(ctx, &query, vars); err != nil {
 		return nil, err
 	}
-	for _, c := range query.Repository.PullRequest.Commits.Edges {
-		if c.Node.Commit.OID == commitRef {
+	pulls := query.Repository.PullRequest.PullRequests()
+	for i, p := range pulls {
+		// An empty ref returns the last commit.
+		if p.Tip.OID == commitRef || (commitRef == "" && i == len(pulls)-1) {
 			// Return as soon as we find the correct ref.
-			return &PullRequest{
-				PullRequestObject: query.Repository.PullRequest.PullRequestObject,
-				Tip:               c.Node.Commit,
-			}, nil
+			p.LinkedIssues = query.Repository.LinkedIssues.ClosingIssuesReferences.LinkedIssues()
+			return p, nil
 		}
 	}
 
 	// Return an error if the commit was not found
-	return nil, fmt.Errorf("commit with ref '%s' does not exist", commitRef)
+	return nil, fmt.Errorf("commit with ref '%s' is not among the last 100 commits of pull request #%d (it may have been force-pushed away)", commitRef, pr)
 }
 
-// UpdateCommitStatus for a given commit (not supported by V4 API).
-func (m *GithubClient) UpdateCommitStatus(commitRef, statusContext, status string) error {
-	c := []string{"concourse-ci"}
-	if statusContext == "" {
-		c = append(c, "status")
-	} else {
-		c = append(c, statusContext)
+// GetPullRequestByCommit returns the pull request that the commit is associated with,
+// with the commit as the tip.
+func (m *GithubClient) GetPullRequestByCommit(ctx context.Context, commitRef string) (*PullRequest, error) {
+	var query struct {
+		Repository struct {
+			Object struct {
+				Commit struct {
+					AssociatedPullRequests struct {
+						Nodes []struct {
+							Number int
+						}
+					} `graphql:"associatedPullRequests(first:1)"`
+				} `graphql:"... on Commit"`
+			} `graphql:"object(oid:$commitOID)"`
+		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
 	}
-	statusContext = strings.Join(c, "/")
 
+	vars := map[string]interface{}{
+		"repositoryOwner": githubv4.String(m.Owner),
+		"repositoryName":  githubv4.String(m.Repository),
+		"commitOID":       githubv4.GitObjectID(commitRef),
+	}
+	if err := m.V4.Query(ctx, &query, vars); err != nil {
+		return nil, err
+	}
+	for _, p := range query.Repository.Object.Commit.AssociatedPullRequests.Nodes {
+		return m.GetPullRequest(ctx, strconv.Itoa(p.Number), commitRef)
+	}
+	return nil, fmt.Errorf("no pull request found for commit '%s'", commitRef)
+}
+
+// UpdateCommitStatus for a given commit (not supported by V4 API).
+func (m *GithubClient) UpdateCommitStatus(ctx context.Context, commitRef, statusContext, status string) error {
 	// Format build page
 	build := os.Getenv("ATC_EXTERNAL_URL")
 	if build != "" {
@@ -244,7 +647,7 @@ func (m *GithubClient) UpdateCommitStatus(commitRef, statusContext, status strin
 	}
 
 	_, _, err := m.V3.Repositories.CreateStatus(
-		context.TODO(),
+		ctx,
 		m.Owner,
 		m.Repository,
 		commitRef,
@@ -258,10 +661,647 @@ func (m *GithubClient) UpdateCommitStatus(commitRef, statusContext, status strin
 	return err
 }
 
+// AddLabels to a pull request (not supported by V4 API).
+func (m *GithubClient) AddLabels(ctx context.Context, prNumber int, labels []string) error {
+	_, _, err := m.V3.Issues.AddLabelsToIssue(
+		ctx,
+		m.Owner,
+		m.Repository,
+		prNumber,
+		labels,
+	)
+	return err
+}
+
+// SubmitReview submits a review (APPROVE, REQUEST_CHANGES or COMMENT) of the commit of a pull request.
+func (m *GithubClient) SubmitReview(ctx context.Context, prNumber int, commitRef, event, body string) error {
+	review := &github.PullRequestReviewRequest{
+		CommitID: github.String(commitRef),
+		Event:    github.String(event),
+	}
+	if body != "" {
+		review.Body = github.String(body)
+	}
+	_, _, err := m.V3.PullRequests.CreateReview(
+		ctx,
+		m.Owner,
+		m.Repository,
+		prNumber,
+		review,
+	)
+	return err
+}
+
+// MergePullRequest merges a pull request with the method (merge, squash or
+// rebase), if its head is still the commit. The title and message of the merge
+// commit default to those chosen by Github when empty.
+func (m *GithubClient) MergePullRequest(ctx context.Context, prNumber int, commitRef, method, title, message string) error {
+	_, _, err := m.V3.PullRequests.Merge(
+		ctx,
+		m.Owner,
+		m.Repository,
+		prNumber,
+		message,
+		&github.PullRequestOptions{
+			CommitTitle: title,
+			MergeMethod: method,
+			SHA:         commitRef,
+		},
+	)
+	return err
+}
+
+// RemoveLabels from a pull request (not supported by V4 API). Labels that are
+// not on the pull request are ignored.
+func (m *GithubClient) RemoveLabels(ctx context.Context, prNumber int, labels []string) error {
+	for _, label := range labels {
+		_, err := m.V3.Issues.RemoveLabelForIssue(
+			ctx,
+			m.Owner,
+			m.Repository,
+			prNumber,
+			label,
+		)
+		if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBranch deletes a branch in the repository (not supported by V4 API).
+func (m *GithubClient) DeleteBranch(ctx context.Context, headRef string) error {
+	_, err := m.V3.Git.DeleteRef(
+		ctx,
+		m.Owner,
+		m.Repository,
+		"heads/"+headRef,
+	)
+	return err
+}
+
+// GetFileContent returns the content of a file at the given ref. An empty
+// string is returned if the file does not exist.
+func (m *GithubClient) GetFileContent(ctx context.Context, path, ref string) (string, error) {
+	file, _, _, err := m.V3.Repositories.GetContents(
+		ctx,
+		m.Owner,
+		m.Repository,
+		path,
+		&github.RepositoryContentGetOptions{Ref: ref},
+	)
+	if err != nil {
+		if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	if file == nil {
+		return "", fmt.Errorf("%s is not a file", path)
+	}
+	return file.GetContent()
+}
+
+// DefaultAPIVersion is the Github API version used when none is configured.
+const DefaultAPIVersion = "2022-11-28"
+
+// apiVersionTransport pins the Github API version on all outgoing requests.
+type apiVersionTransport struct {
+	Version string
+	Base    http.RoundTripper
+}
+
+func (t *apiVersionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Clone the request since a RoundTripper should not modify it.
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("X-GitHub-Api-Version", t.Version)
+	return t.Base.RoundTrip(r)
+}
+
+// logger writes diagnostic messages to stderr (stdout is reserved for the
+// response), as text or as JSON lines if the format is json.
+type logger struct {
+	Format string
+}
+
+// Warnf logs a warning. The fields are only included in JSON lines.
+func (l *logger) Warnf(fields map[string]interface{}, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if l == nil || l.Format != "json" {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", message)
+		return
+	}
+	line := map[string]interface{}{"level": "warning", "message": message}
+	for k, v := range fields {
+		line[k] = v
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", message)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", b)
+}
+
+// DefaultRateLimitWarnThreshold is the remaining rate limit below which a
+// warning is logged, unless configured otherwise.
+const DefaultRateLimitWarnThreshold = 100
+
+// rateLimitTransport logs a warning to stderr when the remaining rate limit
+// reported by Github drops below the threshold.
+type rateLimitTransport struct {
+	Threshold int
+	Log       *logger
+	Base      http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining >= t.Threshold {
+		return resp, nil
+	}
+	reset := "unknown"
+	if epoch, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(epoch, 0).UTC().Format(time.RFC3339)
+	}
+	t.Log.Warnf(map[string]interface{}{"remaining": remaining, "reset": reset},
+		"Github API rate limit is low: %d requests remaining (resets at %s)", remaining, reset)
+	return resp, nil
+}
+
+// cacheTransport caches responses that have an ETag in a directory (which can be
+// shared between pipelines), and makes conditional requests for them with
+// If-None-Match. Github does not count a 304 Not Modified against the rate
+// limit, and the cached response is returned in its place. Entries are keyed
+// by the token, so pipelines only share responses when they use the same token.
+type cacheTransport struct {
+	Dir   string
+	Token string
+	Log   *logger
+	Base  http.RoundTripper
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	// Clone the request since a RoundTripper should not modify it.
+	r := req.WithContext(req.Context())
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	// Only reads are cached: GET requests and GraphQL queries (not mutations).
+	if req.Method != http.MethodGet && !(req.Method == http.MethodPost && isGraphQLQuery(body)) {
+		return t.Base.RoundTrip(r)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s %s\n%s", t.Token, req.Method, req.URL, body)
+	path := filepath.Join(t.Dir, hex.EncodeToString(h.Sum(nil))+".json")
+
+	var cached *cacheEntry
+	if b, err := ioutil.ReadFile(path); err == nil {
+		var entry cacheEntry
+		if err := json.Unmarshal(b, &entry); err == nil && entry.ETag != "" {
+			cached = &entry
+			r.Header.Set("If-None-Match", entry.ETag)
+		}
+	}
+
+	resp, err := t.Base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		resp.Body.Close()
+		// Keep the rate limit headers of the fresh response.
+		header := make(http.Header, len(cached.Header))
+		for k, v := range cached.Header {
+			header[k] = v
+		}
+		for k, v := range resp.Header {
+			if strings.HasPrefix(k, "X-Ratelimit-") {
+				header[k] = v
+			}
+		}
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header = header
+		resp.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		resp.ContentLength = int64(len(cached.Body))
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "":
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+		entry := cacheEntry{ETag: resp.Header.Get("ETag"), Header: resp.Header, Body: b}
+		if err := writeCacheEntry(path, entry); err != nil {
+			t.Log.Warnf(nil, "failed to write to cache: %s", err)
+		}
+	}
+	return resp, nil
+}
+
+// isGraphQLQuery returns true if the body is a GraphQL request that is not a mutation.
+func isGraphQLQuery(body []byte) bool {
+	var request struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.Query == "" {
+		return false
+	}
+	return !strings.HasPrefix(strings.TrimSpace(request.Query), "mutation")
+}
+
+// writeCacheEntry writes the entry to a temporary file that is renamed into
+// place, so concurrent readers never see a partial entry.
+func writeCacheEntry(path string, entry cacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// GetRequiredStatusContexts returns the status contexts required by branch protection
+// for the given base ref. Unprotected branches have no required contexts.
+func (m *GithubClient) GetRequiredStatusContexts(ctx context.Context, baseRef string) ([]string, error) {
+	checks, _, err := m.V3.Repositories.GetRequiredStatusChecks(
+		ctx,
+		m.Owner,
+		m.Repository,
+		baseRef,
+	)
+	if err != nil {
+		if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return checks.Contexts, nil
+}
+
+// GetStatusContexts returns the state of each (at most 100) status context and
+// check run on the commit, keyed by the context (or name of the check run).
+// The state of a check run is its conclusion, which is empty until it completes.
+func (m *GithubClient) GetStatusContexts(ctx context.Context, commitRef string) (map[string]string, error) {
+	var query struct {
+		Repository struct {
+			Object struct {
+				Commit struct {
+					StatusCheckRollup *struct {
+						Contexts struct {
+							Nodes []struct {
+								StatusContext struct {
+									Context string
+									State   string
+								} `graphql:"... on StatusContext"`
+								CheckRun struct {
+									Name       string
+									Conclusion string
+								} `graphql:"... on CheckRun"`
+							}
+						} `graphql:"contexts(first:100)"`
+					}
+				} `graphql:"... on Commit"`
+			} `graphql:"object(oid:$commitOID)"`
+		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
+	}
+
+	vars := map[string]interface{}{
+		"repositoryOwner": githubv4.String(m.Owner),
+		"repositoryName":  githubv4.String(m.Repository),
+		"commitOID":       githubv4.GitObjectID(commitRef),
+	}
+	if err := m.V4.Query(ctx, &query, vars); err != nil {
+		return nil, err
+	}
+	states := make(map[string]string)
+	if rollup := query.Repository.Object.Commit.StatusCheckRollup; rollup != nil {
+		for _, n := range rollup.Contexts.Nodes {
+			if c := n.StatusContext; c.Context != "" {
+				states[c.Context] = c.State
+			}
+			if c := n.CheckRun; c.Name != "" {
+				states[c.Name] = c.Conclusion
+			}
+		}
+	}
+	return states, nil
+}
+
+// LatestRelease returns the tag name of the latest release, falling back to the
+// most recent tag. An empty string is returned if the repository has neither.
+func (m *GithubClient) LatestRelease(ctx context.Context) (string, error) {
+	var query struct {
+		Repository struct {
+			LatestRelease *struct {
+				TagName string
+			}
+			Refs struct {
+				Nodes []struct {
+					Name string
+				}
+			} `graphql:"refs(refPrefix:\"refs/tags/\",last:1,orderBy:{field:TAG_COMMIT_DATE,direction:ASC})"`
+		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
+	}
+
+	vars := map[string]interface{}{
+		"repositoryOwner": githubv4.String(m.Owner),
+		"repositoryName":  githubv4.String(m.Repository),
+	}
+	if err := m.V4.Query(ctx, &query, vars); err != nil {
+		return "", err
+	}
+	if r := query.Repository.LatestRelease; r != nil {
+		return r.TagName, nil
+	}
+	for _, t := range query.Repository.Refs.Nodes {
+		return t.Name, nil
+	}
+	return "", nil
+}
+
+// MaxParticipants is the maximum number of participants listed for a pull request.
+const MaxParticipants = 100
+
+// ListParticipants returns the logins of the users that participated in a pull
+// request (author, commenters and reviewers), capped at MaxParticipants.
+func (m *GithubClient) ListParticipants(ctx context.Context, prNumber int) ([]string, error) {
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				Participants struct {
+					Nodes []struct {
+						Login string
+					}
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+				} `graphql:"participants(first:100,after:$participantsCursor)"`
+			} `graphql:"pullRequest(number:$prNumber)"`
+		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
+	}
+
+	vars := map[string]interface{}{
+		"repositoryOwner":    githubv4.String(m.Owner),
+		"repositoryName":     githubv4.String(m.Repository),
+		"prNumber":           githubv4.Int(prNumber),
+		"participantsCursor": (*githubv4.String)(nil),
+	}
+
+	var logins []string
+	for {
+		if err := m.V4.Query(ctx, &query, vars); err != nil {
+			return nil, err
+		}
+		for _, p := range query.Repository.PullRequest.Participants.Nodes {
+			logins = append(logins, p.Login)
+		}
+		if len(logins) >= MaxParticipants {
+			return logins[:MaxParticipants], nil
+		}
+		if !query.Repository.PullRequest.Participants.PageInfo.HasNextPage {
+			break
+		}
+		vars["participantsCursor"] = query.Repository.PullRequest.Participants.PageInfo.EndCursor
+	}
+	return logins, nil
+}
+
+// ListCommitMessages returns the messages of all the commits in a pull request.
+func (m *GithubClient) ListCommitMessages(ctx context.Context, prNumber int) ([]string, error) {
+	var query struct {
+		Repository struct {
+			PullRequest struct {
+				Commits struct {
+					Nodes []struct {
+						Commit struct {
+							Message string
+						}
+					}
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+				} `graphql:"commits(first:100,after:$commitsCursor)"`
+			} `graphql:"pullRequest(number:$prNumber)"`
+		} `graphql:"repository(owner:$repositoryOwner,name:$repositoryName)"`
+	}
+
+	vars := map[string]interface{}{
+		"repositoryOwner": githubv4.String(m.Owner),
+		"repositoryName":  githubv4.String(m.Repository),
+		"prNumber":        githubv4.Int(prNumber),
+		"commitsCursor":   (*githubv4.String)(nil),
+	}
+
+	var messages []string
+	for {
+		if err := m.V4.Query(ctx, &query, vars); err != nil {
+			return nil, err
+		}
+		for _, c := range query.Repository.PullRequest.Commits.Nodes {
+			messages = append(messages, c.Commit.Message)
+		}
+		if !query.Repository.PullRequest.Commits.PageInfo.HasNextPage {
+			break
+		}
+		vars["commitsCursor"] = query.Repository.PullRequest.Commits.PageInfo.EndCursor
+	}
+	return messages, nil
+}
+
+// accessTokenEnvPrefix marks an access token that should be read from an environment variable.
+const accessTokenEnvPrefix = "env:"
+
+// resolveAccessToken returns the token, reading it from the environment when it is given as env:NAME.
+func resolveAccessToken(token string) (string, error) {
+	if !strings.HasPrefix(token, accessTokenEnvPrefix) {
+		return token, nil
+	}
+	name := strings.TrimPrefix(token, accessTokenEnvPrefix)
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("access_token environment variable %s is empty", name)
+	}
+	return value, nil
+}
+
+// readCABundle returns the PEM encoded certificates of a CA bundle, which is
+// given inline or as the path of a file.
+func readCABundle(bundle string) ([]byte, error) {
+	if strings.Contains(bundle, "-----BEGIN") {
+		return []byte(bundle), nil
+	}
+	b, err := ioutil.ReadFile(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca_bundle: %s", err)
+	}
+	return b, nil
+}
+
+// caCertPool returns the system root CAs together with those in the CA bundle.
+func caCertPool(bundle string) (*x509.CertPool, error) {
+	b, err := readCABundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("ca_bundle must contain PEM encoded certificates")
+	}
+	return pool, nil
+}
+
+// sourceAccessToken returns the access token of the source, which is read from
+// the netrc file when it is not set and use_netrc is enabled.
+func sourceAccessToken(s *Source) (string, error) {
+	if useNetrc, _ := strconv.ParseBool(s.UseNetrc); s.AccessToken != "" || !useNetrc {
+		return resolveAccessToken(s.AccessToken)
+	}
+	path := os.Getenv("NETRC")
+	if path == "" {
+		path = filepath.Join(os.Getenv("HOME"), ".netrc")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read netrc: %s", err)
+	}
+	host := githubHost(s)
+	token := netrcPassword(string(b), host)
+	if token == "" {
+		return "", fmt.Errorf("no password for %s in netrc", host)
+	}
+	return token, nil
+}
+
+// githubHost returns the host of the Github API, as it would be named in a
+// netrc file (i.e. github.com rather than api.github.com).
+func githubHost(s *Source) string {
+	endpoint := s.V3Endpoint
+	if endpoint == "" {
+		endpoint, _ = enterpriseEndpoints(s.Repository)
+	}
+	if u, err := url.Parse(endpoint); err == nil && u.Hostname() != "" {
+		return strings.TrimPrefix(u.Hostname(), "api.")
+	}
+	return "github.com"
+}
+
+// netrcPassword returns the password of the machine in the content of a netrc
+// file, or of the default entry if there is no such machine.
+func netrcPassword(netrc, host string) string {
+	var machine, fallback string
+	var isDefault bool
+	fields := strings.Fields(netrc)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			machine, isDefault = "", false
+			if i+1 < len(fields) {
+				i++
+				machine = fields[i]
+			}
+		case "default":
+			machine, isDefault = "", true
+		case "login", "account":
+			i++
+		case "password":
+			if i+1 >= len(fields) {
+				return fallback
+			}
+			i++
+			if !isDefault && machine == host {
+				return fields[i]
+			}
+			if isDefault && fallback == "" {
+				fallback = fields[i]
+			}
+		case "macdef":
+			// Macro definitions are not supported, and end the entries we read.
+			return fallback
+		}
+	}
+	return fallback
+}
+
+// parseRepository returns the owner and name of a repository given as
+// owner/repo or as a URL (e.g. https://github.com/owner/repo.git).
 func parseRepository(s string) (string, string, error) {
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", "", fmt.Errorf("malformed repository: %s", err)
+		}
+		s = strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	}
 	parts := strings.Split(s, "/")
-	if len(parts) != 2 {
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 		return "", "", errors.New("malformed repository")
 	}
 	return parts[0], parts[1], nil
 }
+
+// enterpriseEndpoints returns the V3 and V4 endpoints of the Github Enterprise
+// host in a repository URL, or empty strings for github.com and owner/repo.
+func enterpriseEndpoints(repository string) (string, string) {
+	if !strings.Contains(repository, "://") {
+		return "", ""
+	}
+	u, err := url.Parse(repository)
+	if err != nil || u.Hostname() == "github.com" {
+		return "", ""
+	}
+	base := u.Scheme + "://" + u.Host
+	return base + "/api/v3/", base + "/api/graphql"
+}
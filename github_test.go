@@ -0,0 +1,1341 @@
+package resource_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itsdalmo/github-pr-resource"
+)
+
+func TestGithubClientAPIVersion(t *testing.T) {
+	tests := []struct {
+		description string
+		apiVersion  string
+		want        string
+	}{
+		{
+			description: "uses the default api version",
+			apiVersion:  "",
+			want:        resource.DefaultAPIVersion,
+		},
+		{
+			description: "uses the configured api version",
+			apiVersion:  "2099-01-01",
+			want:        "2099-01-01",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			var got []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = append(got, r.Header.Get("X-GitHub-Api-Version"))
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == "/graphql" {
+					w.Write([]byte(`{"data":{"addComment":{"subject":{"id":"pr1"}}}}`))
+					return
+				}
+				w.Write([]byte(`[]`))
+			}))
+			defer server.Close()
+
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				V3Endpoint:  server.URL + "/",
+				V4Endpoint:  server.URL + "/graphql",
+				APIVersion:  tc.apiVersion,
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+			if _, _, err := github.ListModifiedFilesPage(context.Background(), 1, 1); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := github.PostComment(context.Background(), "pr1", "comment"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(got) != 2 {
+				t.Fatalf("expected 2 requests, got %d", len(got))
+			}
+			for _, v := range got {
+				if v != tc.want {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", v, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGithubClientGetRequiredStatusContexts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/itsdalmo/test-repository/branches/master/protection/required_status_checks":
+			w.Write([]byte(`{"strict":true,"contexts":["concourse-ci/status","lint"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Branch not protected"}`))
+		}
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+
+	tests := []struct {
+		description string
+		baseRef     string
+		want        []string
+	}{
+		{
+			description: "returns the contexts for a protected branch",
+			baseRef:     "master",
+			want:        []string{"concourse-ci/status", "lint"},
+		},
+		{
+			description: "returns no contexts for an unprotected branch",
+			baseRef:     "develop",
+			want:        nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := github.GetRequiredStatusContexts(context.Background(), tc.baseRef)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubClientGetStatusContexts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"repository":{"object":{"statusCheckRollup":{"contexts":{"nodes":[` +
+			`{"context":"concourse-ci/status","state":"SUCCESS"},` +
+			`{"name":"lint","conclusion":"FAILURE"},` +
+			`{"name":"test","conclusion":null}]}}}}}}`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	got, err := github.GetStatusContexts(context.Background(), "oid1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]string{"concourse-ci/status": "SUCCESS", "lint": "FAILURE", "test": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientProxy(t *testing.T) {
+	var got string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests to a proxy use the absolute URL of the target.
+		got = r.URL.String()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer proxy.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  "http://github.example.com/api/v3/",
+		V4Endpoint:  "http://github.example.com/api/graphql",
+		Proxy:       proxy.URL,
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	if _, _, err := github.ListModifiedFilesPage(context.Background(), 1, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "http://github.example.com/api/v3/repos/itsdalmo/test-repository/pulls/1/files?page=1&per_page=100"; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientLatestRelease(t *testing.T) {
+	tests := []struct {
+		description string
+		response    string
+		want        string
+	}{
+		{
+			description: "returns the latest release",
+			response:    `{"data":{"repository":{"latestRelease":{"tagName":"v1.2.0"},"refs":{"nodes":[{"name":"v1.3.0-rc1"}]}}}}`,
+			want:        "v1.2.0",
+		},
+		{
+			description: "falls back to the latest tag",
+			response:    `{"data":{"repository":{"latestRelease":null,"refs":{"nodes":[{"name":"v1.3.0-rc1"}]}}}}`,
+			want:        "v1.3.0-rc1",
+		},
+		{
+			description: "returns nothing without releases or tags",
+			response:    `{"data":{"repository":{"latestRelease":null,"refs":{"nodes":[]}}}}`,
+			want:        "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				V3Endpoint:  server.URL + "/",
+				V4Endpoint:  server.URL + "/graphql",
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+			got, err := github.LatestRelease(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubClientAccessTokenFromEnv(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	os.Setenv("GITHUB_PR_RESOURCE_TEST_TOKEN", "envtoken")
+	defer os.Unsetenv("GITHUB_PR_RESOURCE_TEST_TOKEN")
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "env:GITHUB_PR_RESOURCE_TEST_TOKEN",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	if _, _, err := github.ListModifiedFilesPage(context.Background(), 1, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "Bearer envtoken"; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientAccessTokenFromMissingEnv(t *testing.T) {
+	os.Unsetenv("GITHUB_PR_RESOURCE_TEST_TOKEN")
+
+	_, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "env:GITHUB_PR_RESOURCE_TEST_TOKEN",
+	})
+	if err == nil {
+		t.Fatal("expected an error for an empty environment variable")
+	}
+}
+
+func TestGithubClientAccessTokenFromNetrc(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+	netrc := filepath.Join(dir, ".netrc")
+	if err := ioutil.WriteFile(netrc, []byte("machine 127.0.0.1 login x-oauth-basic password netrctoken\n"), 0600); err != nil {
+		t.Fatalf("failed to write netrc: %s", err)
+	}
+	os.Setenv("NETRC", netrc)
+	defer os.Unsetenv("NETRC")
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository: "itsdalmo/test-repository",
+		UseNetrc:   "true",
+		V3Endpoint: server.URL + "/",
+		V4Endpoint: server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	if _, _, err := github.ListModifiedFilesPage(context.Background(), 1, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "Bearer netrctoken"; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientVerifyAccess(t *testing.T) {
+	tests := []struct {
+		description string
+		status      int
+		response    string
+		want        string
+	}{
+		{
+			description: "verifies a token that can read the repository",
+			status:      http.StatusOK,
+			response:    `{"data":{"viewer":{"login":"ci"},"repository":{"viewerPermission":"READ"}}}`,
+		},
+		{
+			description: "explains the missing access of a fine-grained token",
+			status:      http.StatusOK,
+			response:    `{"data":{"viewer":{"login":"ci"},"repository":null},"errors":[{"type":"FORBIDDEN","message":"Resource not accessible by personal access token"}]}`,
+			want:        "access token cannot read itsdalmo/test-repository: grant it read access to the contents, pull requests and commit statuses of the repository (or the repo scope for a classic token)",
+		},
+		{
+			description: "explains a repository the token cannot see",
+			status:      http.StatusOK,
+			response:    `{"data":{"viewer":{"login":"ci"},"repository":null},"errors":[{"type":"NOT_FOUND","message":"Could not resolve to a Repository with the name 'itsdalmo/test-repository'."}]}`,
+			want:        "access token cannot read itsdalmo/test-repository: grant it read access to the contents, pull requests and commit statuses of the repository (or the repo scope for a classic token)",
+		},
+		{
+			description: "explains a rejected token",
+			status:      http.StatusUnauthorized,
+			response:    `{"message":"Bad credentials"}`,
+			want:        "access token was rejected by Github (it may be expired or revoked)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			resource.ResetVerifiedAccess()
+			defer resource.ResetVerifiedAccess()
+
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			source := &resource.Source{
+				Repository:   "itsdalmo/test-repository",
+				AccessToken:  "oauthtoken",
+				V3Endpoint:   server.URL + "/",
+				V4Endpoint:   server.URL + "/graphql",
+				VerifyAccess: "true",
+			}
+			_, err := resource.NewGithubClient(source)
+			if tc.want != "" {
+				if err == nil || err.Error() != tc.want {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tc.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			// The access is only verified once per process.
+			if _, err := resource.NewGithubClient(source); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if requests != 1 {
+				t.Errorf("expected 1 request, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestGithubClientCABundle(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	// The untrusted handshake is expected, do not log it.
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(path, []byte(bundle), 0644); err != nil {
+		t.Fatalf("failed to write ca bundle: %s", err)
+	}
+
+	tests := []struct {
+		description string
+		caBundle    string
+		wantErr     bool
+	}{
+		{
+			description: "does not trust the CA by default",
+			caBundle:    "",
+			wantErr:     true,
+		},
+		{
+			description: "trusts the CA of an inline bundle",
+			caBundle:    bundle,
+		},
+		{
+			description: "trusts the CA of a bundle file",
+			caBundle:    path,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				V3Endpoint:  server.URL + "/",
+				V4Endpoint:  server.URL + "/graphql",
+				CABundle:    tc.caBundle,
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+			_, _, err = github.ListModifiedFilesPage(context.Background(), 1, 1)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "certificate") {
+					t.Errorf("expected a certificate error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestNetrcPassword(t *testing.T) {
+	netrc := `
+machine github.com
+  login x-oauth-basic
+  password publictoken
+
+machine github.example.com login ci password enterprisetoken
+default login anonymous password defaulttoken
+`
+
+	tests := []struct {
+		description string
+		netrc       string
+		host        string
+		want        string
+	}{
+		{
+			description: "resolves the token for github.com",
+			netrc:       netrc,
+			host:        "github.com",
+			want:        "publictoken",
+		},
+		{
+			description: "resolves the token for an enterprise host",
+			netrc:       netrc,
+			host:        "github.example.com",
+			want:        "enterprisetoken",
+		},
+		{
+			description: "falls back to the default entry",
+			netrc:       netrc,
+			host:        "github.local",
+			want:        "defaulttoken",
+		},
+		{
+			description: "returns nothing for an unknown host without a default",
+			netrc:       "machine github.com password publictoken",
+			host:        "github.local",
+			want:        "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := resource.NetrcPassword(tc.netrc, tc.host); got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubHost(t *testing.T) {
+	tests := []struct {
+		description string
+		source      resource.Source
+		want        string
+	}{
+		{
+			description: "defaults to github.com",
+			source:      resource.Source{Repository: "itsdalmo/test-repository"},
+			want:        "github.com",
+		},
+		{
+			description: "strips api. from the endpoint",
+			source:      resource.Source{Repository: "itsdalmo/test-repository", V3Endpoint: "https://api.github.com/"},
+			want:        "github.com",
+		},
+		{
+			description: "uses the host of an enterprise endpoint",
+			source:      resource.Source{Repository: "itsdalmo/test-repository", V3Endpoint: "https://github.example.com/api/v3/"},
+			want:        "github.example.com",
+		},
+		{
+			description: "uses the host of an enterprise repository URL",
+			source:      resource.Source{Repository: "https://github.example.com/itsdalmo/test-repository"},
+			want:        "github.example.com",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := resource.GithubHost(&tc.source); got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubClientListParticipants(t *testing.T) {
+	tests := []struct {
+		description string
+		pages       [][]string
+		want        int
+		requests    int
+	}{
+		{
+			description: "lists participants across pages",
+			pages:       [][]string{{"login1", "login2"}, {"login3"}},
+			want:        3,
+			requests:    2,
+		},
+		{
+			description: "caps the number of participants",
+			pages:       [][]string{testLogins(60), testLogins(60), testLogins(60)},
+			want:        resource.MaxParticipants,
+			requests:    2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				page := tc.pages[requests]
+				requests++
+				var nodes []string
+				for _, login := range page {
+					nodes = append(nodes, fmt.Sprintf(`{"login":%q}`, login))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"data":{"repository":{"pullRequest":{"participants":{"nodes":[%s],"pageInfo":{"endCursor":"cursor%d","hasNextPage":%t}}}}}}`,
+					strings.Join(nodes, ","), requests, requests < len(tc.pages))
+			}))
+			defer server.Close()
+
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				V3Endpoint:  server.URL + "/",
+				V4Endpoint:  server.URL + "/graphql",
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+			got, err := github.ListParticipants(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(got) != tc.want {
+				t.Errorf("expected %d participants, got %d", tc.want, len(got))
+			}
+			if requests != tc.requests {
+				t.Errorf("expected %d requests, got %d", tc.requests, requests)
+			}
+		})
+	}
+}
+
+func TestGithubClientGetPullRequestCommit(t *testing.T) {
+	tests := []struct {
+		description string
+		commitRef   string
+		want        string
+		wantErr     bool
+	}{
+		{
+			description: "returns the last commit without a ref",
+			commitRef:   "",
+			want:        "oid2",
+		},
+		{
+			description: "returns an older commit than the tip",
+			commitRef:   "oid1",
+			want:        "oid1",
+		},
+		{
+			description: "fails for a commit that was force-pushed away",
+			commitRef:   "oid0",
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data":{"repository":{"pullRequest":{"number":1,"commits":{"edges":[{"node":{"commit":{"oid":"oid1"}}},{"node":{"commit":{"oid":"oid2"}}}]}}}}}`))
+			}))
+			defer server.Close()
+
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				V3Endpoint:  server.URL + "/",
+				V4Endpoint:  server.URL + "/graphql",
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+			pull, err := github.GetPullRequest(context.Background(), "1", tc.commitRef)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "force-pushed") {
+					t.Errorf("expected an error about a force-push, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := pull.Tip.OID; got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubClientListCommitMessages(t *testing.T) {
+	pages := [][]string{{"first", "wip [skip ci]"}, {"last"}}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := pages[requests]
+		requests++
+		var nodes []string
+		for _, message := range page {
+			nodes = append(nodes, fmt.Sprintf(`{"commit":{"message":%q}}`, message))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"repository":{"pullRequest":{"commits":{"nodes":[%s],"pageInfo":{"endCursor":"cursor%d","hasNextPage":%t}}}}}}`,
+			strings.Join(nodes, ","), requests, requests < len(pages))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	got, err := github.ListCommitMessages(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"first", "wip [skip ci]", "last"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if requests != len(pages) {
+		t.Errorf("expected %d requests, got: %d", len(pages), requests)
+	}
+}
+
+func TestGithubClientListLinkedIssues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"repository":{"pullRequest":{"closingIssuesReferences":{"nodes":[` +
+			`{"number":12,"labels":{"nodes":[]}},{"number":34,"labels":{"nodes":[{"name":"bug"}]}}]}}}}}`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	got, err := github.ListLinkedIssues(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []resource.LinkedIssue{{Number: 12}, {Number: 34, Labels: []string{"bug"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientGetPullRequestLinkedIssues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"repository":{` +
+			`"pullRequest":{"number":1,"commits":{"edges":[{"node":{"commit":{"oid":"oid1"}}}]}},` +
+			`"linkedIssues":{"closingIssuesReferences":{"nodes":[{"number":12,"labels":{"nodes":[{"name":"bug"}]}}]}}}}}`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	pull, err := github.GetPullRequest(context.Background(), "1", "oid1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := pull.LinkedIssues, []resource.LinkedIssue{{Number: 12, Labels: []string{"bug"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientGetPullRequestAutoMerge(t *testing.T) {
+	tests := []struct {
+		description string
+		node        string
+		want        bool
+	}{
+		{
+			description: "auto-merge is enabled",
+			node:        `,"autoMergeRequest":{"enabledAt":"2018-01-01T00:00:00Z"}`,
+			want:        true,
+		},
+		{
+			description: "auto-merge is not enabled",
+			node:        `,"autoMergeRequest":null`,
+			want:        false,
+		},
+		{
+			description: "an absent auto-merge node counts as not queued",
+			node:        ``,
+			want:        false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data":{"repository":{"pullRequest":{"number":1` + tc.node + `,"commits":{"edges":[{"node":{"commit":{"oid":"oid1"}}}]}}}}}`))
+			}))
+			defer server.Close()
+
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				V3Endpoint:  server.URL + "/",
+				V4Endpoint:  server.URL + "/graphql",
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+			pull, err := github.GetPullRequest(context.Background(), "1", "")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := pull.QueuedForAutoMerge(); got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubClientSearchPullRequests(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables struct {
+				SearchQuery string `json:"searchQuery"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %s", err)
+		}
+		query = body.Variables.SearchQuery
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"search":{"nodes":[` +
+			`{"number":1,"repository":{"nameWithOwner":"itsdalmo/api"},"commits":{"edges":[{"node":{"commit":{"oid":"oid1"}}}]}},` +
+			`{},` +
+			`{"number":1,"repository":{"nameWithOwner":"itsdalmo/web"},"commits":{"edges":[{"node":{"commit":{"oid":"oid2"}}}]}}` +
+			`],"pageInfo":{"hasNextPage":false}}}}`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		SearchQuery: "org:itsdalmo is:open",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	pulls, err := github.SearchPullRequests(context.Background(), "org:itsdalmo is:open", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "is:pr org:itsdalmo is:open"; query != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", query, want)
+	}
+
+	// Results that are not pull requests (e.g. issues) have no commits and are left out.
+	var got []string
+	for _, p := range pulls {
+		got = append(got, p.Repository.NameWithOwner+"#"+strconv.Itoa(p.Number)+"@"+p.Tip.OID)
+	}
+	if want := []string{"itsdalmo/api#1@oid1", "itsdalmo/web#1@oid2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientGetPullRequestLabelsAndReopened(t *testing.T) {
+	// The current version is newer than the commit, but older than when the PR was reopened.
+	current := resource.Version{PR: "1", Commit: "oid1", CommittedDate: time.Date(2018, 5, 14, 8, 0, 0, 0, time.UTC)}
+	node := `{"number":1,` +
+		`"labels":{"nodes":[{"name":"wip"}]},` +
+		`"timelineItems":{"nodes":[{"createdAt":"2018-05-15T08:00:00Z"}]},` +
+		`"commits":{"edges":[{"node":{"commit":{"oid":"oid2","committedDate":"2018-05-13T08:00:00Z"}}}]}}`
+
+	tests := []struct {
+		description string
+		source      resource.Source
+		want        string
+	}{
+		{
+			description: "the commit is not newer than the current version",
+			source:      resource.Source{},
+			want:        "oid1",
+		},
+		{
+			description: "trigger_on_reopen uses when the pull request was reopened",
+			source:      resource.Source{TriggerOnReopen: "true"},
+			want:        "oid2",
+		},
+		{
+			description: "ignore_labels uses the labels of the pull request",
+			source:      resource.Source{TriggerOnReopen: "true", IgnoreLabels: []string{"wip"}},
+			want:        "oid1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"data":{"repository":{"pullRequest":` + node + `}}}`))
+			}))
+			defer server.Close()
+
+			source := tc.source
+			source.Repository = "itsdalmo/test-repository"
+			source.AccessToken = "oauthtoken"
+			source.V3Endpoint = server.URL + "/"
+			source.V4Endpoint = server.URL + "/graphql"
+			source.WebhookOptimized = "true"
+			github, err := resource.NewGithubClient(&source)
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+
+			output, err := resource.Check(resource.CheckRequest{Source: source, Version: current}, github)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(output) != 1 {
+				t.Fatalf("expected a single version, got: %v", output)
+			}
+			if got := output[0].Commit; got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubClientGetPullRequestCommitCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"repository":{"pullRequest":{"number":1,"commitCount":{"totalCount":12},"commits":{"edges":[{"node":{"commit":{"oid":"oid1"}}}]}}}}}`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	pull, err := github.GetPullRequest(context.Background(), "1", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := pull.CommitCount.TotalCount, 12; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientRepositoryUnavailable(t *testing.T) {
+	tests := []struct {
+		description string
+		response    string
+		archived    bool
+		wantErr     bool
+	}{
+		{
+			description: "returns the repository",
+			response:    `{"data":{"repository":{"url":"https://github.com/itsdalmo/test-repository","isArchived":false}}}`,
+		},
+		{
+			description: "returns an archived repository",
+			response:    `{"data":{"repository":{"url":"https://github.com/itsdalmo/test-repository","isArchived":true}}}`,
+			archived:    true,
+		},
+		{
+			description: "fails for a missing repository",
+			response:    `{"data":{"repository":null},"errors":[{"type":"NOT_FOUND","message":"Could not resolve to a Repository with the name 'itsdalmo/test-repository'."}]}`,
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+				V3Endpoint:  server.URL + "/",
+				V4Endpoint:  server.URL + "/graphql",
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+			repository, err := github.GetRepository(context.Background())
+			if tc.wantErr {
+				if _, ok := err.(*resource.RepositoryUnavailableError); !ok {
+					t.Errorf("expected a RepositoryUnavailableError, got: %v", err)
+				}
+				if _, err := github.ListPullRequests(context.Background(), nil, 1, nil); err == nil || err.Error() != "repository itsdalmo/test-repository was not found (or is not accessible with the access token)" {
+					t.Errorf("unexpected error from listing pull requests: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := repository.IsArchived; got != tc.archived {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.archived)
+			}
+		})
+	}
+}
+
+func testLogins(n int) []string {
+	var logins []string
+	for i := 0; i < n; i++ {
+		logins = append(logins, fmt.Sprintf("login%d", i))
+	}
+	return logins
+}
+
+func TestParseRepository(t *testing.T) {
+	tests := []struct {
+		description string
+		repository  string
+		owner       string
+		name        string
+		wantErr     bool
+	}{
+		{
+			description: "parses owner/repo",
+			repository:  "itsdalmo/test-repository",
+			owner:       "itsdalmo",
+			name:        "test-repository",
+		},
+		{
+			description: "parses a github.com url",
+			repository:  "https://github.com/itsdalmo/test-repository.git",
+			owner:       "itsdalmo",
+			name:        "test-repository",
+		},
+		{
+			description: "parses an enterprise url",
+			repository:  "https://github.example.com/itsdalmo/test-repository",
+			owner:       "itsdalmo",
+			name:        "test-repository",
+		},
+		{
+			description: "fails for a malformed repository",
+			repository:  "itsdalmo",
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			owner, name, err := resource.ParseRepository(tc.repository)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if owner != tc.owner || name != tc.name {
+				t.Errorf("\ngot:\n%s/%s\nwant:\n%s/%s\n", owner, name, tc.owner, tc.name)
+			}
+		})
+	}
+}
+
+func TestGithubClientEnterpriseRepositoryURL(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  server.URL + "/itsdalmo/test-repository.git",
+		AccessToken: "oauthtoken",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	if _, _, err := github.ListModifiedFilesPage(context.Background(), 1, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "/api/v3/repos/itsdalmo/test-repository/pulls/1/files"; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientRateLimitWarning(t *testing.T) {
+	tests := []struct {
+		description string
+		remaining   string
+		logFormat   string
+		want        string
+	}{
+		{
+			description: "warns when the remaining budget is below the threshold",
+			remaining:   "9",
+			want:        "warning: Github API rate limit is low: 9 requests remaining (resets at 2018-05-14T10:51:58Z)\n",
+		},
+		{
+			description: "does not warn above the threshold",
+			remaining:   "10",
+			want:        "",
+		},
+		{
+			description: "warns with a json line",
+			remaining:   "9",
+			logFormat:   "json",
+			want:        `{"level":"warning","message":"Github API rate limit is low: 9 requests remaining (resets at 2018-05-14T10:51:58Z)","remaining":9,"reset":"2018-05-14T10:51:58Z"}` + "\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-RateLimit-Remaining", tc.remaining)
+				w.Header().Set("X-RateLimit-Reset", "1526295118")
+				w.Write([]byte(`{"data":{"repository":{"latestRelease":null,"refs":{"nodes":[]}}}}`))
+			}))
+			defer server.Close()
+
+			github, err := resource.NewGithubClient(&resource.Source{
+				Repository:             "itsdalmo/test-repository",
+				AccessToken:            "oauthtoken",
+				V3Endpoint:             server.URL + "/",
+				V4Endpoint:             server.URL + "/graphql",
+				RateLimitWarnThreshold: 10,
+				LogFormat:              tc.logFormat,
+			})
+			if err != nil {
+				t.Fatalf("failed to create github client: %s", err)
+			}
+
+			// Capture stderr while querying.
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("failed to create pipe: %s", err)
+			}
+			stderr := os.Stderr
+			os.Stderr = w
+			defer func() { os.Stderr = stderr }()
+
+			_, err = github.LatestRelease(context.Background())
+			w.Close()
+			os.Stderr = stderr
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("failed to read stderr: %s", err)
+			}
+			if got := string(b); got != tc.want {
+				t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGithubClientListModifiedFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(`[{"filename":"terraform/main.tf"}]`))
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next"`, r.Host, r.URL.Path))
+		w.Write([]byte(`[{"filename":"README.md"},{"filename":"travis.yml"}]`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	got, err := github.ListModifiedFiles(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"README.md", "travis.yml", "terraform/main.tf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientModifiedFilesCapWarning(t *testing.T) {
+	var files []string
+	for i := 0; i < 100; i++ {
+		files = append(files, fmt.Sprintf(`{"filename":"file%d"}`, i))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(files, ",") + "]"))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+
+	// Capture stderr while listing the last page before the cap.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	_, _, err = github.ListModifiedFilesPage(context.Background(), 1, resource.MaxModifiedFiles/100)
+	w.Close()
+	os.Stderr = stderr
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read stderr: %s", err)
+	}
+	if want := "warning: pull request #1 changes more files than the Github API lists (3000), paths are only matched against those\n"; string(b) != want {
+		t.Errorf("\ngot:\n%q\nwant:\n%q\n", string(b), want)
+	}
+}
+
+func TestGithubClientRemoveLabels(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Label does not exist"}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	github, err := resource.NewGithubClient(&resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+	})
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+	if err := github.RemoveLabels(context.Background(), 1, []string{"missing", "ci-failed"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{
+		"DELETE /repos/itsdalmo/test-repository/issues/1/labels/missing",
+		"DELETE /repos/itsdalmo/test-repository/issues/1/labels/ci-failed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGithubClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	source := resource.Source{
+		Repository:  "itsdalmo/test-repository",
+		AccessToken: "oauthtoken",
+		V3Endpoint:  server.URL + "/",
+		V4Endpoint:  server.URL + "/graphql",
+		Timeout:     "100ms",
+	}
+	github, err := resource.NewGithubClient(&source)
+	if err != nil {
+		t.Fatalf("failed to create github client: %s", err)
+	}
+
+	// The context of the check is cancelled at the timeout of the source.
+	_, err = resource.Check(resource.CheckRequest{Source: source}, github)
+	if !errors.Is(err, resource.ErrTimeout) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, resource.ErrTimeout)
+	}
+}
+
+func TestGithubClientCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "github-pr-resource")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"etag1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"etag1"`)
+		w.Write([]byte(`[{"filename":"README.md"}]`))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		// A new client for each request, as with separate pipelines sharing the cache.
+		github, err := resource.NewGithubClient(&resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+			V3Endpoint:  server.URL + "/",
+			V4Endpoint:  server.URL + "/graphql",
+			CacheDir:    dir,
+		})
+		if err != nil {
+			t.Fatalf("failed to create github client: %s", err)
+		}
+		files, _, err := github.ListModifiedFilesPage(context.Background(), 1, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := files, []string{"README.md"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+		}
+	}
+	if got, want := conditional, []string{"", `"etag1"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, want)
+	}
+}
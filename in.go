@@ -1,41 +1,140 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Get (business logic)
 func Get(request GetRequest, github Github, git Git, outputDir string) (*GetResponse, error) {
-	pull, err := github.GetPullRequest(request.Version.PR, request.Version.Commit)
+	ctx, cancel, err := request.Source.timeoutContext()
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve pull request: %s", err)
+		return nil, err
+	}
+	defer cancel()
+	response, err := get(ctx, request, github, git, outputDir)
+	return response, classifyError(ctx, err)
+}
+
+func get(ctx context.Context, request GetRequest, github Github, git Git, outputDir string) (*GetResponse, error) {
+	if err := request.Params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	owner, repository, err := parseRepository(request.Source.ForVersion(request.Version).Repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
+	}
+	pull, err := github.GetPullRequest(ctx, request.Version.PR, request.Version.Commit)
+	if err != nil {
+		// Versions that refer to the PR by (a possibly migrated) node ID are resolved by the commit instead.
+		if _, convErr := strconv.Atoi(request.Version.PR); convErr != nil {
+			pull, err = github.GetPullRequestByCommit(ctx, request.Version.Commit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve pull request: %w", err)
+		}
 	}
 
-	// Clone the repository and fetch the PR
-	if err := git.Init(); err != nil {
+	// Fail fast if the PR no longer changes files matching the paths (e.g. they were reverted since the check).
+	var files []string
+	reassertPaths := request.Params.ReassertPaths && (len(request.Source.Paths) > 0 || len(request.Source.IgnorePaths) > 0)
+	if request.Params.ListChangedFiles || reassertPaths {
+		files, err = github.ListModifiedFiles(ctx, pull.Number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list modified files: %w", err)
+		}
+	}
+	if reassertPaths {
+		match, err := MatchesPaths(files, request.Source.Paths, request.Source.IgnorePaths)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			return nil, fmt.Errorf("pull request #%d no longer changes files matching paths (and not ignore_paths)", pull.Number)
+		}
+	}
+
+	// Clone the repository and fetch the PR (the git client is expected to use the clone directory)
+	if err := os.MkdirAll(filepath.Join(outputDir, request.Params.CloneDir), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	if err := git.Init(ctx); err != nil {
 		return nil, err
 	}
-	if err := git.Pull(pull.Repository.URL); err != nil {
+	// Configure the identity used for the merge commit (before git_config, which can override it).
+	name, email := "concourse-ci", "concourse@local"
+	if request.Params.GitUserName != "" {
+		name = request.Params.GitUserName
+	}
+	if request.Params.GitUserEmail != "" {
+		email = request.Params.GitUserEmail
+	}
+	if err := git.Config(ctx, "user.name", name); err != nil {
 		return nil, err
 	}
-	if err := git.Fetch(pull.Repository.URL, pull.Number); err != nil {
+	if err := git.Config(ctx, "user.email", email); err != nil {
 		return nil, err
 	}
-
-	// Create a branch from the base ref and merge PR into it
-	baseSHA, err := git.RevParse(pull.BaseRefName)
+	for _, key := range sortedKeys(request.Params.GitConfig) {
+		if err := git.Config(ctx, key, request.Params.GitConfig[key]); err != nil {
+			return nil, err
+		}
+	}
+	if len(request.Params.SparsePaths) > 0 {
+		if err := git.SparseCheckout(ctx, request.Params.SparsePaths); err != nil {
+			return nil, err
+		}
+	}
+	// Clone from a mirror if configured (API calls still go to Github).
+	cloneURL := pull.Repository.URL
+	if t := request.Source.GitURLTemplate; t != "" {
+		cloneURL = strings.NewReplacer("{owner}", owner, "{repo}", repository).Replace(t)
+	}
+	retries := DefaultCloneRetries
+	if r := request.Params.CloneRetries; r != nil {
+		retries = *r
+	}
+	if err := retry(retries, func() error { return git.Pull(ctx, cloneURL) }); err != nil {
+		return nil, err
+	}
+	// Additional refs to fetch along with the PR head, e.g. refs/pull/{pr}/merge.
+	var refspecs []string
+	for _, r := range request.Params.FetchRefs {
+		refspecs = append(refspecs, strings.Replace(r, "{pr}", strconv.Itoa(pull.Number), -1))
+	}
+	if request.Params.FetchTags {
+		refspecs = append(refspecs, "+refs/tags/*:refs/tags/*")
+	}
+	// Fetch again if the tip is missing after the fetch. The tip is the commit of
+	// the version, which is not necessarily the latest commit of the PR.
+	err = retry(retries, func() error {
+		if err := git.Fetch(ctx, cloneURL, pull.Number, refspecs); err != nil {
+			return err
+		}
+		if err := git.VerifyCommit(ctx, pull.Tip.OID); err != nil {
+			return fmt.Errorf("commit %s is not reachable from pull request #%d (it may have been force-pushed away): %w", pull.Tip.OID, pull.Number, err)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := git.Checkout(baseSHA); err != nil {
+
+	baseSHA, err := git.RevParse(ctx, pull.BaseRefName)
+	if err != nil {
 		return nil, err
 	}
-	if err := git.Merge(pull.Tip.OID); err != nil {
+	mergeBaseSHA, err := git.MergeBase(ctx, baseSHA, pull.Tip.OID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -43,39 +142,378 @@ func Get(request GetRequest, github Github, git Git, outputDir string) (*GetResp
 	var metadata Metadata
 	metadata.Add("pr", strconv.Itoa(pull.Number))
 	metadata.Add("url", pull.URL)
+	metadata.Add("owner", owner)
+	metadata.Add("repo", repository)
 	metadata.Add("head_sha", pull.Tip.OID)
 	metadata.Add("base_sha", baseSHA)
-	metadata.Add("message", pull.Tip.Message)
-	metadata.Add("author", pull.Tip.Author.User.Login)
+	metadata.Add("merge_base_sha", mergeBaseSHA)
+	metadata.Add("base_ref", pull.BaseRefName)
+	metadata.Add("head_ref", pull.HeadRefName)
+	message := pull.Tip.Message
+	if request.Params.MessageSource == "title" {
+		message = pull.Title
+	}
+	metadata.Add("message", message)
+	// Authors without a linked Github account have no login, fall back to the git author name.
+	author := pull.Tip.Author.User.Login
+	if author == "" {
+		author = pull.Tip.Author.Name
+	}
+	metadata.Add("author", author)
+	// The email is empty when it is kept private.
+	if email := pull.Tip.Author.Email; email != "" {
+		metadata.Add("author_email", email)
+	}
+	metadata.Add("draft", strconv.FormatBool(pull.IsDraft))
+	metadata.Add("created_at", pull.CreatedAt.UTC().Format(time.RFC3339))
+	metadata.Add("updated_at", pull.UpdatedAt.UTC().Format(time.RFC3339))
+	metadata.Add("commit_count", strconv.Itoa(pull.CommitCount.TotalCount))
+	if len(pull.LinkedIssues) > 0 {
+		var issues []string
+		for _, i := range pull.LinkedIssues {
+			issues = append(issues, strconv.Itoa(i.Number))
+		}
+		metadata.Add("closes_issues", strings.Join(issues, ","))
+	}
+
+	if request.Params.IncludeLatestRelease {
+		release, err := github.LatestRelease(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest release: %w", err)
+		}
+		if release != "" {
+			metadata.Add("latest_release", release)
+		}
+	}
+
+	if request.Params.IncludeParticipants {
+		participants, err := github.ListParticipants(ctx, pull.Number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list participants: %w", err)
+		}
+		metadata.Add("participants", strings.Join(participants, ","))
+	}
+
+	if request.Params.ListChangedFiles {
+		metadata.Add("file_count", strconv.Itoa(len(files)))
+	}
+
+	// Create a branch from the base ref and merge PR into it
+	mergeMessage := strings.NewReplacer(
+		"{pr}", strconv.Itoa(pull.Number),
+		"{title}", pull.Title,
+		"{sha}", pull.Tip.OID,
+	).Replace(request.Params.MergeCommitMessage)
+	if request.Params.SkipMerge {
+		// Check out the PR as-is, leaving merge conflicts for the pipeline to inspect.
+		if err := git.Checkout(ctx, pull.Tip.OID, pull.Tip.OID); err != nil {
+			return nil, err
+		}
+	} else if request.Params.IntegrationTool == "squash" {
+		if err := git.Checkout(ctx, baseSHA, baseSHA); err != nil {
+			return nil, err
+		}
+		if err := git.MergeSquash(ctx, pull.Tip.OID, request.Params.MergeStrategyOption, mergeMessage); err != nil {
+			return nil, mergeFailed(ctx, git, outputDir, request, metadata, err)
+		}
+		mergeSHA, err := git.RevParse(ctx, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		metadata.Add("merge_sha", mergeSHA)
+	} else {
+		if err := git.Checkout(ctx, baseSHA, baseSHA); err != nil {
+			return nil, err
+		}
+		if err := git.Merge(ctx, pull.Tip.OID, request.Params.MergeStrategyOption, mergeMessage); err != nil {
+			return nil, mergeFailed(ctx, git, outputDir, request, metadata, err)
+		}
+	}
+
+	// The tree SHA identifies the contents of the checkout.
+	treeSHA, err := git.RevParse(ctx, "HEAD^{tree}")
+	if err != nil {
+		return nil, err
+	}
+	metadata.Add("tree_sha", treeSHA)
 
 	// Write version and metadata for reuse in PUT
+	if err := writeVersionAndMetadata(outputDir, request.Version, metadata); err != nil {
+		return nil, err
+	}
+	if request.Params.MetadataEnvFile {
+		if err := writeMetadataEnv(filepath.Join(outputDir, ".git", "resource", "metadata.env"), metadata); err != nil {
+			return nil, err
+		}
+	}
+	if request.Params.ListChangedFiles {
+		b, err := json.Marshal(files)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal changed files: %w", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(outputDir, ".git", "resource", "changed_files.json"), b, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write changed files: %w", err)
+		}
+	}
+	if request.Params.WriteDiff {
+		if err := writeDiff(ctx, git, baseSHA, pull.Tip.OID, filepath.Join(outputDir, ".git", "resource", "changes.diff")); err != nil {
+			return nil, err
+		}
+	}
+
+	return &GetResponse{
+		Version:  request.Version,
+		Metadata: metadata,
+	}, nil
+}
+
+// writeDiff streams the diff of the PR (since it diverged from the base) to a file.
+func writeDiff(ctx context.Context, git Git, base, head, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create diff file: %w", err)
+	}
+	if err := git.Diff(ctx, base, head, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write diff file: %w", err)
+	}
+	return nil
+}
+
+// GetBatch fetches and merges each PR of a batch version into a subdirectory
+// (named after the PR number) of the output directory, using a git client
+// created for the clone directory within each subdirectory (and the
+// subdirectory itself as its output directory).
+func GetBatch(request GetRequest, github Github, newGit func(dir, outputDir string) (Git, error), outputDir string) (*GetResponse, error) {
+	ctx, cancel, err := request.Source.timeoutContext()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	response, err := getBatch(ctx, request, github, newGit, outputDir)
+	return response, classifyError(ctx, err)
+}
+
+func getBatch(ctx context.Context, request GetRequest, github Github, newGit func(dir, outputDir string) (Git, error), outputDir string) (*GetResponse, error) {
+	versions, err := request.Version.BatchVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	var prs []string
+	for _, v := range versions {
+		dir := filepath.Join(outputDir, v.PR)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create pr directory: %w", err)
+		}
+		git, err := newGit(filepath.Join(dir, request.Params.CloneDir), dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create git client: %w", err)
+		}
+		pr := request
+		pr.Version = v
+		if _, err := get(ctx, pr, github, git, dir); err != nil {
+			return nil, fmt.Errorf("failed to get pr %s: %w", v.PR, err)
+		}
+		prs = append(prs, v.PR)
+	}
+
+	var metadata Metadata
+	metadata.Add("prs", strings.Join(prs, ","))
+	if err := writeVersionAndMetadata(outputDir, request.Version, metadata); err != nil {
+		return nil, err
+	}
+	return &GetResponse{
+		Version:  request.Version,
+		Metadata: metadata,
+	}, nil
+}
+
+// DefaultCloneRetries is the number of times cloning and fetching the PR is
+// retried by Get, unless configured otherwise.
+const DefaultCloneRetries = 2
+
+// retryDelay is the time to wait between retries (replaced in tests).
+var retryDelay = 2 * time.Second
+
+// retry calls fn until it succeeds, at most retries+1 times.
+func retry(retries int, fn func() error) error {
+	var err error
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			time.Sleep(retryDelay)
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// sortedKeys returns the keys of a map in sorted order.
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeFailed writes the version and metadata (with merge_conflict set) so they
+// can be inspected by a follow-on task, and returns a MergeConflictError. The
+// conflicting files are also listed in metadata and conflicts.txt if reported.
+func mergeFailed(ctx context.Context, git Git, outputDir string, request GetRequest, metadata Metadata, mergeErr error) error {
+	var files []string
+	metadata.Add("merge_conflict", "true")
+	if request.Params.OnConflict == "report" {
+		var err error
+		files, err = git.ConflictedFiles(ctx)
+		if err != nil {
+			return err
+		}
+		metadata.Add("conflicts", strings.Join(files, ","))
+	}
+
+	if err := writeVersionAndMetadata(outputDir, request.Version, metadata); err != nil {
+		return err
+	}
+	if request.Params.OnConflict == "report" {
+		path := filepath.Join(outputDir, ".git", "resource", "conflicts.txt")
+		if err := ioutil.WriteFile(path, []byte(strings.Join(files, "\n")+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write conflicts: %w", err)
+		}
+	}
+	return &MergeConflictError{Files: files, Err: mergeErr}
+}
+
+// writeVersionAndMetadata to the resource directory in .git.
+func writeVersionAndMetadata(outputDir string, version Version, metadata Metadata) error {
 	path := filepath.Join(outputDir, ".git", "resource")
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("failed to create output directory: %s", err)
+		return fmt.Errorf("failed to create output directory: %w", err)
 	}
-	b, err := json.Marshal(request.Version)
+	b, err := json.Marshal(version)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal version: %s", err)
+		return fmt.Errorf("failed to marshal version: %w", err)
 	}
 	if err := ioutil.WriteFile(filepath.Join(path, "version.json"), b, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write version: %s", err)
+		return fmt.Errorf("failed to write version: %w", err)
 	}
 	b, err = json.Marshal(metadata)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal metadata: %s", err)
+		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 	if err := ioutil.WriteFile(filepath.Join(path, "metadata.json"), b, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write metadata: %s", err)
+		return fmt.Errorf("failed to write metadata: %w", err)
 	}
+	return nil
+}
 
-	return &GetResponse{
-		Version:  request.Version,
-		Metadata: metadata,
-	}, nil
+// writeMetadataEnv writes the metadata as KEY='value' lines that can be sourced
+// by a shell. Keys are uppercased and prefixed with PR_ (pr itself is PR_NUMBER).
+func writeMetadataEnv(path string, metadata Metadata) error {
+	var b strings.Builder
+	for _, m := range metadata {
+		key := "PR_" + strings.ToUpper(m.Name)
+		if m.Name == "pr" {
+			key = "PR_NUMBER"
+		}
+		fmt.Fprintf(&b, "%s='%s'\n", key, strings.Replace(m.Value, "'", `'\''`, -1))
+	}
+	if err := ioutil.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write metadata env file: %w", err)
+	}
+	return nil
+}
+
+// MergeConflictError is returned by Get when the PR does not merge cleanly
+// into the base. Files are only listed when conflicts are reported.
+type MergeConflictError struct {
+	Files []string
+	Err   error
+}
+
+func (e *MergeConflictError) Error() string {
+	if len(e.Files) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: conflicts in: %s", e.Err, strings.Join(e.Files, ", "))
+}
+
+// Is returns true for ErrMergeConflict.
+func (e *MergeConflictError) Is(target error) bool {
+	return target == ErrMergeConflict
+}
+
+// Unwrap returns the error of the merge.
+func (e *MergeConflictError) Unwrap() error {
+	return e.Err
 }
 
 // GetParameters ...
-type GetParameters struct{}
+type GetParameters struct {
+	SkipMerge            bool              `json:"skip_merge"`
+	OnConflict           string            `json:"on_conflict"`
+	GitConfig            map[string]string `json:"git_config"`
+	ListChangedFiles     bool              `json:"list_changed_files"`
+	IncludeLatestRelease bool              `json:"include_latest_release"`
+	GitUserName          string            `json:"git_user_name"`
+	GitUserEmail         string            `json:"git_user_email"`
+	IncludeParticipants  bool              `json:"include_participants"`
+	CloneRetries         *int              `json:"clone_retries"`
+	IntegrationTool      string            `json:"integration_tool"`
+	CloneDir             string            `json:"clone_dir"`
+	SparsePaths          []string          `json:"sparse_paths"`
+	Verbose              bool              `json:"verbose"`
+	MergeStrategyOption  string            `json:"merge_strategy_option"`
+	WriteDiff            bool              `json:"write_diff"`
+	MetadataEnvFile      bool              `json:"metadata_env_file"`
+	MergeCommitMessage   string            `json:"merge_commit_message"`
+	ReassertPaths        bool              `json:"reassert_paths"`
+	MessageSource        string            `json:"message_source"`
+	FetchTags            bool              `json:"fetch_tags"`
+	FetchRefs            []string          `json:"fetch_refs"`
+}
+
+// Validate the get parameters.
+func (p *GetParameters) Validate() error {
+	switch p.OnConflict {
+	case "", "fail", "report":
+	default:
+		return fmt.Errorf("unknown on_conflict: %s", p.OnConflict)
+	}
+	switch p.IntegrationTool {
+	case "", "merge", "squash":
+	default:
+		return fmt.Errorf("unknown integration_tool: %s", p.IntegrationTool)
+	}
+	switch p.MessageSource {
+	case "", "commit", "title":
+	default:
+		return fmt.Errorf("unknown message_source: %s", p.MessageSource)
+	}
+	if dir := filepath.Clean(p.CloneDir); filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+		return errors.New("clone_dir must be a path within the output directory")
+	}
+	if p.CloneRetries != nil && *p.CloneRetries < 0 {
+		return errors.New("clone_retries must not be negative")
+	}
+	for _, r := range p.FetchRefs {
+		if r == "" || strings.HasPrefix(r, "-") {
+			return fmt.Errorf("fetch_refs must contain refspecs: %q", r)
+		}
+	}
+	for key := range p.GitConfig {
+		if key == "" {
+			return errors.New("git_config keys must not be empty")
+		}
+	}
+	return nil
+}
 
 // GetRequest ...
 type GetRequest struct {
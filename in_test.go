@@ -1,12 +1,16 @@
 package resource_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,6 +27,9 @@ func TestGet(t *testing.T) {
 		source         resource.Source
 		version        resource.Version
 		parameters     resource.GetParameters
+		mergeMessage   string
+		gitConfig      [][2]string
+		gitUser        [2]string
 		pullRequest    *resource.PullRequest
 		versionString  string
 		metadataString string
@@ -39,9 +46,110 @@ func TestGet(t *testing.T) {
 				CommittedDate: time.Time{},
 			},
 			parameters:     resource.GetParameters{},
+			gitUser:        [2]string{"concourse-ci", "concourse@local"},
 			pullRequest:    createTestPR(1, false),
 			versionString:  `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`,
-			metadataString: `[{"name":"pr","value":"1"},{"name":"url","value":"pr1 url"},{"name":"head_sha","value":"oid1"},{"name":"base_sha","value":"sha"},{"name":"message","value":"commit message1"},{"name":"author","value":"login1"}]`,
+			metadataString: `[{"name":"pr","value":"1"},{"name":"url","value":"pr1 url"},{"name":"owner","value":"itsdalmo"},{"name":"repo","value":"test-repository"},{"name":"head_sha","value":"oid1"},{"name":"base_sha","value":"sha"},{"name":"merge_base_sha","value":"mergebase"},{"name":"base_ref","value":"master"},{"name":"head_ref","value":"pr1"},{"name":"message","value":"commit message1"},{"name":"author","value":"login1"},{"name":"author_email","value":"user1@example.com"},{"name":"draft","value":"false"},{"name":"created_at","value":"0001-01-01T00:00:00Z"},{"name":"updated_at","value":"0001-01-01T00:00:00Z"},{"name":"commit_count","value":"0"},{"name":"tree_sha","value":"tree"}]`,
+		},
+		{
+			description: "get passes the merge strategy option",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters:     resource.GetParameters{MergeStrategyOption: "theirs"},
+			gitUser:        [2]string{"concourse-ci", "concourse@local"},
+			pullRequest:    createTestPR(1, false),
+			versionString:  `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`,
+			metadataString: `[{"name":"pr","value":"1"},{"name":"url","value":"pr1 url"},{"name":"owner","value":"itsdalmo"},{"name":"repo","value":"test-repository"},{"name":"head_sha","value":"oid1"},{"name":"base_sha","value":"sha"},{"name":"merge_base_sha","value":"mergebase"},{"name":"base_ref","value":"master"},{"name":"head_ref","value":"pr1"},{"name":"message","value":"commit message1"},{"name":"author","value":"login1"},{"name":"author_email","value":"user1@example.com"},{"name":"draft","value":"false"},{"name":"created_at","value":"0001-01-01T00:00:00Z"},{"name":"updated_at","value":"0001-01-01T00:00:00Z"},{"name":"commit_count","value":"0"},{"name":"tree_sha","value":"tree"}]`,
+		},
+		{
+			description: "get renders the merge commit message",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters:     resource.GetParameters{MergeCommitMessage: "Merge #{pr}: {title} ({sha})"},
+			mergeMessage:   "Merge #1: pr1 title (oid1)",
+			gitUser:        [2]string{"concourse-ci", "concourse@local"},
+			pullRequest:    createTestPR(1, false),
+			versionString:  `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`,
+			metadataString: `[{"name":"pr","value":"1"},{"name":"url","value":"pr1 url"},{"name":"owner","value":"itsdalmo"},{"name":"repo","value":"test-repository"},{"name":"head_sha","value":"oid1"},{"name":"base_sha","value":"sha"},{"name":"merge_base_sha","value":"mergebase"},{"name":"base_ref","value":"master"},{"name":"head_ref","value":"pr1"},{"name":"message","value":"commit message1"},{"name":"author","value":"login1"},{"name":"author_email","value":"user1@example.com"},{"name":"draft","value":"false"},{"name":"created_at","value":"0001-01-01T00:00:00Z"},{"name":"updated_at","value":"0001-01-01T00:00:00Z"},{"name":"commit_count","value":"0"},{"name":"tree_sha","value":"tree"}]`,
+		},
+		{
+			description: "get can skip merging the base",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.GetParameters{
+				SkipMerge: true,
+			},
+			gitUser:        [2]string{"concourse-ci", "concourse@local"},
+			pullRequest:    createTestPR(1, false),
+			versionString:  `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`,
+			metadataString: `[{"name":"pr","value":"1"},{"name":"url","value":"pr1 url"},{"name":"owner","value":"itsdalmo"},{"name":"repo","value":"test-repository"},{"name":"head_sha","value":"oid1"},{"name":"base_sha","value":"sha"},{"name":"merge_base_sha","value":"mergebase"},{"name":"base_ref","value":"master"},{"name":"head_ref","value":"pr1"},{"name":"message","value":"commit message1"},{"name":"author","value":"login1"},{"name":"author_email","value":"user1@example.com"},{"name":"draft","value":"false"},{"name":"created_at","value":"0001-01-01T00:00:00Z"},{"name":"updated_at","value":"0001-01-01T00:00:00Z"},{"name":"commit_count","value":"0"},{"name":"tree_sha","value":"tree"}]`,
+		},
+		{
+			description: "get applies git config before pulling",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.GetParameters{
+				GitConfig: map[string]string{
+					"http.sslCAInfo":   "/etc/ssl/ca.pem",
+					"http.extraHeader": "Authorization: Basic token",
+				},
+			},
+			gitConfig: [][2]string{
+				{"http.extraHeader", "Authorization: Basic token"},
+				{"http.sslCAInfo", "/etc/ssl/ca.pem"},
+			},
+			gitUser:        [2]string{"concourse-ci", "concourse@local"},
+			pullRequest:    createTestPR(1, false),
+			versionString:  `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`,
+			metadataString: `[{"name":"pr","value":"1"},{"name":"url","value":"pr1 url"},{"name":"owner","value":"itsdalmo"},{"name":"repo","value":"test-repository"},{"name":"head_sha","value":"oid1"},{"name":"base_sha","value":"sha"},{"name":"merge_base_sha","value":"mergebase"},{"name":"base_ref","value":"master"},{"name":"head_ref","value":"pr1"},{"name":"message","value":"commit message1"},{"name":"author","value":"login1"},{"name":"author_email","value":"user1@example.com"},{"name":"draft","value":"false"},{"name":"created_at","value":"0001-01-01T00:00:00Z"},{"name":"updated_at","value":"0001-01-01T00:00:00Z"},{"name":"commit_count","value":"0"},{"name":"tree_sha","value":"tree"}]`,
+		},
+		{
+			description: "get configures a custom identity for the merge",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.GetParameters{
+				GitUserName:  "ci-bot",
+				GitUserEmail: "ci-bot@example.com",
+			},
+			gitUser:        [2]string{"ci-bot", "ci-bot@example.com"},
+			pullRequest:    createTestPR(1, false),
+			versionString:  `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`,
+			metadataString: `[{"name":"pr","value":"1"},{"name":"url","value":"pr1 url"},{"name":"owner","value":"itsdalmo"},{"name":"repo","value":"test-repository"},{"name":"head_sha","value":"oid1"},{"name":"base_sha","value":"sha"},{"name":"merge_base_sha","value":"mergebase"},{"name":"base_ref","value":"master"},{"name":"head_ref","value":"pr1"},{"name":"message","value":"commit message1"},{"name":"author","value":"login1"},{"name":"author_email","value":"user1@example.com"},{"name":"draft","value":"false"},{"name":"created_at","value":"0001-01-01T00:00:00Z"},{"name":"updated_at","value":"0001-01-01T00:00:00Z"},{"name":"commit_count","value":"0"},{"name":"tree_sha","value":"tree"}]`,
 		},
 	}
 
@@ -51,17 +159,19 @@ func TestGet(t *testing.T) {
 			defer ctrl.Finish()
 
 			github := mocks.NewMockGithub(ctrl)
-			github.EXPECT().GetPullRequest(tc.version.PR, tc.version.Commit).Times(1).Return(tc.pullRequest, nil)
+			github.EXPECT().GetPullRequest(gomock.Any(), tc.version.PR, tc.version.Commit).Times(1).Return(tc.pullRequest, nil)
 
 			git := mocks.NewMockGit(ctrl)
-			gomock.InOrder(
-				git.EXPECT().Init().Times(1).Return(nil),
-				git.EXPECT().Pull(tc.pullRequest.Repository.URL).Times(1).Return(nil),
-				git.EXPECT().Fetch(tc.pullRequest.Repository.URL, tc.pullRequest.Number).Times(1).Return(nil),
-				git.EXPECT().RevParse(tc.pullRequest.BaseRefName).Times(1).Return("sha", nil),
-				git.EXPECT().Checkout("sha").Times(1).Return(nil),
-				git.EXPECT().Merge(tc.pullRequest.Tip.OID).Times(1).Return(nil),
-			)
+			expectGet(git, tc.pullRequest, getExpectations{
+				user:      tc.gitUser,
+				config:    tc.gitConfig,
+				strategy:  tc.parameters.MergeStrategyOption,
+				message:   tc.mergeMessage,
+				skipMerge: tc.parameters.SkipMerge,
+			})
+			if tc.parameters.SkipMerge {
+				git.EXPECT().Merge(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
 
 			dir := createTestDirectory(t)
 			defer os.RemoveAll(dir)
@@ -90,6 +200,192 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetListChangedFiles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+	files := []string{"README.md", "main.go", "terraform/main.tf"}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+	github.EXPECT().ListModifiedFiles(gomock.Any(), pull.Number).Times(1).Return(files, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{ListChangedFiles: true},
+	}
+	output, err := resource.Get(input, github, git, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var count string
+	for _, m := range output.Metadata {
+		if m.Name == "file_count" {
+			count = m.Value
+		}
+	}
+	if got, want := count, strconv.Itoa(len(files)); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	changed := readTestFile(t, filepath.Join(dir, ".git", "resource", "changed_files.json"))
+	if got, want := changed, `["README.md","main.go","terraform/main.tf"]`; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestGetIncludeLatestRelease(t *testing.T) {
+	tests := []struct {
+		description string
+		release     string
+		want        string
+	}{
+		{
+			description: "adds the latest release to metadata",
+			release:     "v1.2.0",
+			want:        `{"name":"latest_release","value":"v1.2.0"}`,
+		},
+		{
+			description: "omits the latest release when there is none",
+			release:     "",
+			want:        "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+			version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+			github.EXPECT().LatestRelease(gomock.Any()).Times(1).Return(tc.release, nil)
+
+			git := mocks.NewMockGit(ctrl)
+			expectGet(git, pull, getExpectations{})
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: version,
+				Params:  resource.GetParameters{IncludeLatestRelease: true},
+			}
+			if _, err := resource.Get(input, github, git, dir); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+			if tc.want == "" && strings.Contains(metadata, "latest_release") {
+				t.Errorf("expected no latest_release in metadata, got:\n%s", metadata)
+			}
+			if tc.want != "" && !strings.Contains(metadata, tc.want) {
+				t.Errorf("expected metadata to contain %s, got:\n%s", tc.want, metadata)
+			}
+		})
+	}
+}
+
+func TestGetReportsConflicts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{mergeErr: errors.New("merge failed: exit status 1")},
+		git.EXPECT().ConflictedFiles(gomock.Any()).Times(1).Return([]string{"README.md", "main.go"}, nil),
+	)
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{OnConflict: "report"},
+	}
+	_, err := resource.Get(input, github, git, dir)
+	conflict, ok := err.(*resource.MergeConflictError)
+	if !ok {
+		t.Fatalf("expected a merge conflict error, got: %v", err)
+	}
+	if !errors.Is(err, resource.ErrMergeConflict) {
+		t.Errorf("expected the error to be classified as ErrMergeConflict, got: %v", err)
+	}
+	if got, want := conflict.Files, []string{"README.md", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	conflicts := readTestFile(t, filepath.Join(dir, ".git", "resource", "conflicts.txt"))
+	if got, want := conflicts, "README.md\nmain.go\n"; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+	for _, want := range []string{`{"name":"merge_conflict","value":"true"}`, `{"name":"conflicts","value":"README.md,main.go"}`} {
+		if !strings.Contains(metadata, want) {
+			t.Errorf("expected metadata to contain %s, got:\n%s", want, metadata)
+		}
+	}
+}
+
+func TestGetMergeConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+	mergeErr := errors.New("merge failed: exit status 1")
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{mergeErr: mergeErr})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+	}
+	_, err := resource.Get(input, github, git, dir)
+	conflict, ok := err.(*resource.MergeConflictError)
+	if !ok {
+		t.Fatalf("expected a merge conflict error, got: %v", err)
+	}
+	if conflict.Err != mergeErr {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", conflict.Err, mergeErr)
+	}
+
+	versionString := readTestFile(t, filepath.Join(dir, ".git", "resource", "version.json"))
+	if got, want := versionString, `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+	if want := `{"name":"merge_conflict","value":"true"}`; !strings.Contains(metadata, want) {
+		t.Errorf("expected metadata to contain %s, got:\n%s", want, metadata)
+	}
+}
+
 func createTestPR(count int, skipCI bool) *resource.PullRequest {
 	n := strconv.Itoa(count)
 	d := time.Now().AddDate(0, 0, -count)
@@ -98,7 +394,7 @@ func createTestPR(count int, skipCI bool) *resource.PullRequest {
 		m = "[skip ci]" + m
 	}
 
-	return &resource.PullRequest{
+	pr := &resource.PullRequest{
 		PullRequestObject: resource.PullRequestObject{
 			ID:          fmt.Sprintf("pr%s", n),
 			Number:      count,
@@ -106,7 +402,7 @@ func createTestPR(count int, skipCI bool) *resource.PullRequest {
 			URL:         fmt.Sprintf("pr%s url", n),
 			BaseRefName: "master",
 			HeadRefName: fmt.Sprintf("pr%s", n),
-			Repository: struct{ URL string }{
+			Repository: resource.RepositoryObject{
 				URL: fmt.Sprintf("repo%s url", n),
 			},
 		},
@@ -115,13 +411,12 @@ func createTestPR(count int, skipCI bool) *resource.PullRequest {
 			OID:           fmt.Sprintf("oid%s", n),
 			CommittedDate: githubv4.DateTime{Time: d},
 			Message:       m,
-			Author: struct{ User struct{ Login string } }{
-				User: struct{ Login string }{
-					Login: fmt.Sprintf("login%s", n),
-				},
-			},
 		},
 	}
+	pr.Tip.Author.Name = fmt.Sprintf("name%s", n)
+	pr.Tip.Author.Email = fmt.Sprintf("user%s@example.com", n)
+	pr.Tip.Author.User.Login = fmt.Sprintf("login%s", n)
+	return pr
 }
 
 func createTestDirectory(t *testing.T) string {
@@ -139,3 +434,985 @@ func readTestFile(t *testing.T, path string) string {
 	}
 	return string(b)
 }
+
+// getExpectations describes how a get drives git. The zero value is a get
+// with default parameters, so tests only set what they change.
+type getExpectations struct {
+	init        func(context.Context)
+	user        [2]string
+	config      [][2]string
+	sparsePaths []string
+	url         string
+	fetchRefs   []string
+	strategy    string
+	message     string
+	skipMerge   bool
+	squash      bool
+	mergeErr    error
+}
+
+// expectGet installs the git calls made by a get of pull in order, followed
+// by the calls in after.
+func expectGet(git *mocks.MockGit, pull *resource.PullRequest, e getExpectations, after ...*gomock.Call) {
+	user := e.user
+	if user == ([2]string{}) {
+		user = [2]string{"concourse-ci", "concourse@local"}
+	}
+	url := e.url
+	if url == "" {
+		url = pull.Repository.URL
+	}
+
+	init := git.EXPECT().Init(gomock.Any()).Times(1).Return(nil)
+	if e.init != nil {
+		init.Do(e.init)
+	}
+	calls := []*gomock.Call{
+		init,
+		git.EXPECT().Config(gomock.Any(), "user.name", user[0]).Times(1).Return(nil),
+		git.EXPECT().Config(gomock.Any(), "user.email", user[1]).Times(1).Return(nil),
+	}
+	for _, c := range e.config {
+		calls = append(calls, git.EXPECT().Config(gomock.Any(), c[0], c[1]).Times(1).Return(nil))
+	}
+	if e.sparsePaths != nil {
+		calls = append(calls, git.EXPECT().SparseCheckout(gomock.Any(), e.sparsePaths).Times(1).Return(nil))
+	}
+	calls = append(calls,
+		git.EXPECT().Pull(gomock.Any(), url).Times(1).Return(nil),
+		git.EXPECT().Fetch(gomock.Any(), url, pull.Number, e.fetchRefs).Times(1).Return(nil),
+		git.EXPECT().VerifyCommit(gomock.Any(), pull.Tip.OID).Times(1).Return(nil),
+		git.EXPECT().RevParse(gomock.Any(), pull.BaseRefName).Times(1).Return("sha", nil),
+		git.EXPECT().MergeBase(gomock.Any(), "sha", pull.Tip.OID).Times(1).Return("mergebase", nil),
+	)
+	switch {
+	case e.skipMerge:
+		calls = append(calls, git.EXPECT().Checkout(gomock.Any(), pull.Tip.OID, pull.Tip.OID).Times(1).Return(nil))
+	case e.squash:
+		calls = append(calls,
+			git.EXPECT().Checkout(gomock.Any(), "sha", "sha").Times(1).Return(nil),
+			git.EXPECT().MergeSquash(gomock.Any(), pull.Tip.OID, e.strategy, e.message).Times(1).Return(nil),
+			git.EXPECT().RevParse(gomock.Any(), "HEAD").Times(1).Return("squashed", nil),
+		)
+	default:
+		calls = append(calls,
+			git.EXPECT().Checkout(gomock.Any(), "sha", "sha").Times(1).Return(nil),
+			git.EXPECT().Merge(gomock.Any(), pull.Tip.OID, e.strategy, e.message).Times(1).Return(e.mergeErr),
+		)
+	}
+	if e.mergeErr == nil {
+		calls = append(calls, git.EXPECT().RevParse(gomock.Any(), "HEAD^{tree}").Times(1).Return("tree", nil))
+	}
+	gomock.InOrder(append(calls, after...)...)
+}
+
+func TestGetBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pulls := []*resource.PullRequest{createTestPR(1, false), createTestPR(2, false)}
+	version := resource.Version{Batch: "1:oid1,2:oid2"}
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	github := mocks.NewMockGithub(ctrl)
+	gits := make(map[string]resource.Git)
+	for _, pull := range pulls {
+		github.EXPECT().GetPullRequest(gomock.Any(), strconv.Itoa(pull.Number), pull.Tip.OID).Times(1).Return(pull, nil)
+
+		git := mocks.NewMockGit(ctrl)
+		expectGet(git, pull, getExpectations{})
+		gits[filepath.Join(dir, strconv.Itoa(pull.Number))] = git
+	}
+	newGit := func(dir, outputDir string) (resource.Git, error) {
+		git, ok := gits[dir]
+		if !ok {
+			t.Fatalf("unexpected directory: %s", dir)
+		}
+		if outputDir != dir {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", outputDir, dir)
+		}
+		return git, nil
+	}
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+	}
+	output, err := resource.GetBatch(input, github, newGit, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := output.Version, version; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{
+			path: filepath.Join(dir, ".git", "resource", "version.json"),
+			want: `{"pr":"","commit":"","committed":"0001-01-01T00:00:00Z","batch":"1:oid1,2:oid2"}`,
+		},
+		{
+			path: filepath.Join(dir, ".git", "resource", "metadata.json"),
+			want: `[{"name":"prs","value":"1,2"}]`,
+		},
+		{
+			path: filepath.Join(dir, "1", ".git", "resource", "version.json"),
+			want: `{"pr":"1","commit":"oid1","committed":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			path: filepath.Join(dir, "2", ".git", "resource", "version.json"),
+			want: `{"pr":"2","commit":"oid2","committed":"0001-01-01T00:00:00Z"}`,
+		},
+	}
+	for _, tc := range tests {
+		if got := readTestFile(t, tc.path); got != tc.want {
+			t.Errorf("%s:\ngot:\n%v\nwant:\n%v\n", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestGetIncludeParticipants(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+	github.EXPECT().ListParticipants(gomock.Any(), pull.Number).Times(1).Return([]string{"login1", "reviewer", "commenter"}, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{IncludeParticipants: true},
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+	if want := `{"name":"participants","value":"login1,reviewer,commenter"}`; !strings.Contains(metadata, want) {
+		t.Errorf("expected metadata to contain %s, got:\n%s", want, metadata)
+	}
+}
+
+func TestGetAuthorMetadata(t *testing.T) {
+	tests := []struct {
+		description string
+		login       string
+		email       string
+		want        []string
+		absent      string
+	}{
+		{
+			description: "uses the login of the author",
+			login:       "login1",
+			email:       "user1@example.com",
+			want:        []string{`{"name":"author","value":"login1"}`, `{"name":"author_email","value":"user1@example.com"}`},
+		},
+		{
+			description: "falls back to the git author name without a login",
+			login:       "",
+			email:       "user1@example.com",
+			want:        []string{`{"name":"author","value":"name1"}`, `{"name":"author_email","value":"user1@example.com"}`},
+		},
+		{
+			description: "omits the email when it is private",
+			login:       "login1",
+			email:       "",
+			want:        []string{`{"name":"author","value":"login1"}`},
+			absent:      "author_email",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+			pull.Tip.Author.User.Login = tc.login
+			pull.Tip.Author.Email = tc.email
+			version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+			git := mocks.NewMockGit(ctrl)
+			expectGet(git, pull, getExpectations{})
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: version,
+			}
+			if _, err := resource.Get(input, github, git, dir); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+			for _, want := range tc.want {
+				if !strings.Contains(metadata, want) {
+					t.Errorf("expected metadata to contain %s, got:\n%s", want, metadata)
+				}
+			}
+			if tc.absent != "" && strings.Contains(metadata, tc.absent) {
+				t.Errorf("expected no %s in metadata, got:\n%s", tc.absent, metadata)
+			}
+		})
+	}
+}
+
+func TestGetDraftMetadata(t *testing.T) {
+	tests := []struct {
+		description string
+		draft       bool
+		want        string
+	}{
+		{
+			description: "records a draft pull request",
+			draft:       true,
+			want:        `{"name":"draft","value":"true"}`,
+		},
+		{
+			description: "records a pull request that is ready for review",
+			draft:       false,
+			want:        `{"name":"draft","value":"false"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+			pull.IsDraft = tc.draft
+			version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+			git := mocks.NewMockGit(ctrl)
+			expectGet(git, pull, getExpectations{})
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: version,
+			}
+			if _, err := resource.Get(input, github, git, dir); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+			if !strings.Contains(metadata, tc.want) {
+				t.Errorf("expected metadata to contain %s, got:\n%s", tc.want, metadata)
+			}
+		})
+	}
+}
+
+func TestGetClosesIssuesMetadata(t *testing.T) {
+	tests := []struct {
+		description string
+		issues      []resource.LinkedIssue
+		want        bool
+	}{
+		{
+			description: "lists the issues closed by the pull request",
+			issues:      []resource.LinkedIssue{{Number: 12}, {Number: 34, Labels: []string{"bug"}}},
+			want:        true,
+		},
+		{
+			description: "omits the field when no issues are closed",
+			issues:      nil,
+			want:        false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+			pull.LinkedIssues = tc.issues
+			version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+			git := mocks.NewMockGit(ctrl)
+			expectGet(git, pull, getExpectations{})
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: version,
+			}
+			if _, err := resource.Get(input, github, git, dir); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+			field := `{"name":"closes_issues","value":"12,34"}`
+			if got := strings.Contains(metadata, field); got != tc.want {
+				t.Errorf("expected metadata to contain %s: %v, got:\n%s", field, tc.want, metadata)
+			}
+			if !tc.want && strings.Contains(metadata, "closes_issues") {
+				t.Errorf("expected closes_issues to be omitted, got:\n%s", metadata)
+			}
+		})
+	}
+}
+
+func TestGetMessageSource(t *testing.T) {
+	tests := []struct {
+		description   string
+		messageSource string
+		want          string
+	}{
+		{
+			description:   "uses the commit message by default",
+			messageSource: "",
+			want:          "commit message1",
+		},
+		{
+			description:   "uses the commit message",
+			messageSource: "commit",
+			want:          "commit message1",
+		},
+		{
+			description:   "uses the pull request title",
+			messageSource: "title",
+			want:          "pr1 title",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+			version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+			git := mocks.NewMockGit(ctrl)
+			expectGet(git, pull, getExpectations{})
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: version,
+				Params:  resource.GetParameters{MessageSource: tc.messageSource},
+			}
+			output, err := resource.Get(input, github, git, dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var got string
+			for _, m := range output.Metadata {
+				if m.Name == "message" {
+					got = m.Value
+				}
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetCommitCountMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	pull.CommitCount.TotalCount = 12
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+	if want := `{"name":"commit_count","value":"12"}`; !strings.Contains(metadata, want) {
+		t.Errorf("expected metadata to contain %s, got:\n%s", want, metadata)
+	}
+}
+
+func TestGetFetchRefs(t *testing.T) {
+	tests := []struct {
+		description string
+		fetchTags   bool
+		fetchRefs   []string
+		want        []string
+	}{
+		{
+			description: "only fetches the pull request head by default",
+			want:        nil,
+		},
+		{
+			description: "fetches additional refs of the pull request",
+			fetchRefs:   []string{"refs/pull/{pr}/merge:refs/pull/{pr}/merge"},
+			want:        []string{"refs/pull/1/merge:refs/pull/1/merge"},
+		},
+		{
+			description: "fetches tags",
+			fetchTags:   true,
+			fetchRefs:   []string{"refs/heads/release"},
+			want:        []string{"refs/heads/release", "+refs/tags/*:refs/tags/*"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+			version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+			git := mocks.NewMockGit(ctrl)
+			expectGet(git, pull, getExpectations{fetchRefs: tc.want})
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: version,
+				Params:  resource.GetParameters{FetchTags: tc.fetchTags, FetchRefs: tc.fetchRefs},
+			}
+			if _, err := resource.Get(input, github, git, dir); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestGetTimestampMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	pull.CreatedAt = githubv4.DateTime{Time: time.Date(2018, time.May, 14, 10, 51, 58, 0, time.FixedZone("CEST", 2*60*60))}
+	pull.UpdatedAt = githubv4.DateTime{Time: time.Date(2018, time.May, 15, 8, 0, 0, 0, time.UTC)}
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+	for _, want := range []string{
+		`{"name":"created_at","value":"2018-05-14T08:51:58Z"}`,
+		`{"name":"updated_at","value":"2018-05-15T08:00:00Z"}`,
+	} {
+		if !strings.Contains(metadata, want) {
+			t.Errorf("expected metadata to contain %s, got:\n%s", want, metadata)
+		}
+	}
+}
+
+func TestGetGitURLTemplate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+	mirror := "https://mirror.internal/itsdalmo/test-repository.git"
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{url: mirror})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source: resource.Source{
+			Repository:     "itsdalmo/test-repository",
+			AccessToken:    "oauthtoken",
+			GitURLTemplate: "https://mirror.internal/{owner}/{repo}.git",
+		},
+		Version: version,
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestGetWriteDiff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+	diff := "diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-old\n+new\n"
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{},
+		git.EXPECT().Diff(gomock.Any(), "sha", pull.Tip.OID, gomock.Any()).Times(1).DoAndReturn(func(_ context.Context, base, head string, w io.Writer) error {
+			_, err := io.WriteString(w, diff)
+			return err
+		}),
+	)
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{WriteDiff: true},
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := readTestFile(t, filepath.Join(dir, ".git", "resource", "changes.diff")); got != diff {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, diff)
+	}
+}
+
+func TestGetMetadataEnvFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	pull.Tip.Message = "it's done"
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{MetadataEnvFile: true},
+	}
+	output, err := resource.Get(input, github, git, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.env")), "\n"), "\n")
+	if len(lines) != len(output.Metadata) {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(output.Metadata), len(lines), strings.Join(lines, "\n"))
+	}
+	for i, want := range []string{
+		"PR_NUMBER='1'",
+		"PR_URL='pr1 url'",
+		"PR_OWNER='itsdalmo'",
+		"PR_REPO='test-repository'",
+		"PR_HEAD_SHA='oid1'",
+		"PR_BASE_SHA='sha'",
+		"PR_MERGE_BASE_SHA='mergebase'",
+		"PR_BASE_REF='master'",
+		"PR_HEAD_REF='pr1'",
+		`PR_MESSAGE='it'\''s done'`,
+	} {
+		if lines[i] != want {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", lines[i], want)
+		}
+	}
+}
+
+func TestGetReassertPaths(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+	github.EXPECT().ListModifiedFiles(gomock.Any(), pull.Number).Times(1).Return([]string{"README.md"}, nil)
+
+	// Nothing is cloned when the paths no longer match.
+	git := mocks.NewMockGit(ctrl)
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source: resource.Source{
+			Repository:  "itsdalmo/test-repository",
+			AccessToken: "oauthtoken",
+			Paths:       []string{"terraform/*"},
+		},
+		Version: version,
+		Params:  resource.GetParameters{ReassertPaths: true},
+	}
+	_, err := resource.Get(input, github, git, dir)
+	if want := "pull request #1 no longer changes files matching paths (and not ignore_paths)"; err == nil || err.Error() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, want)
+	}
+}
+
+func TestGetRetriesClone(t *testing.T) {
+	defer resource.SetRetryDelay(0)()
+	zero, one := 0, 1
+
+	tests := []struct {
+		description string
+		retries     *int
+		expect      func(git *mocks.MockGit, pull *resource.PullRequest)
+		wantErr     bool
+	}{
+		{
+			description: "retries a failed fetch",
+			expect: func(git *mocks.MockGit, pull *resource.PullRequest) {
+				gomock.InOrder(
+					git.EXPECT().Pull(gomock.Any(), pull.Repository.URL).Times(1).Return(nil),
+					git.EXPECT().Fetch(gomock.Any(), pull.Repository.URL, pull.Number, nil).Times(1).Return(errors.New("fetch failed")),
+					git.EXPECT().Fetch(gomock.Any(), pull.Repository.URL, pull.Number, nil).Times(1).Return(nil),
+					git.EXPECT().VerifyCommit(gomock.Any(), pull.Tip.OID).Times(1).Return(nil),
+				)
+			},
+		},
+		{
+			description: "fetches again when the tip is missing",
+			expect: func(git *mocks.MockGit, pull *resource.PullRequest) {
+				gomock.InOrder(
+					git.EXPECT().Pull(gomock.Any(), pull.Repository.URL).Times(1).Return(nil),
+					git.EXPECT().Fetch(gomock.Any(), pull.Repository.URL, pull.Number, nil).Times(1).Return(nil),
+					git.EXPECT().VerifyCommit(gomock.Any(), pull.Tip.OID).Times(1).Return(errors.New("commit is missing")),
+					git.EXPECT().Fetch(gomock.Any(), pull.Repository.URL, pull.Number, nil).Times(1).Return(nil),
+					git.EXPECT().VerifyCommit(gomock.Any(), pull.Tip.OID).Times(1).Return(nil),
+				)
+			},
+		},
+		{
+			description: "does not retry with zero retries",
+			retries:     &zero,
+			expect: func(git *mocks.MockGit, pull *resource.PullRequest) {
+				git.EXPECT().Pull(gomock.Any(), pull.Repository.URL).Times(1).Return(errors.New("pull failed"))
+			},
+			wantErr: true,
+		},
+		{
+			description: "fails when the configured retries are exhausted",
+			retries:     &one,
+			expect: func(git *mocks.MockGit, pull *resource.PullRequest) {
+				git.EXPECT().Pull(gomock.Any(), pull.Repository.URL).Times(2).Return(errors.New("pull failed"))
+			},
+			wantErr: true,
+		},
+		{
+			description: "fails when the commit was force-pushed away",
+			retries:     &one,
+			expect: func(git *mocks.MockGit, pull *resource.PullRequest) {
+				git.EXPECT().Pull(gomock.Any(), pull.Repository.URL).Times(1).Return(nil)
+				git.EXPECT().Fetch(gomock.Any(), pull.Repository.URL, pull.Number, nil).Times(2).Return(nil)
+				git.EXPECT().VerifyCommit(gomock.Any(), pull.Tip.OID).Times(2).Return(errors.New("commit is missing"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+			version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+			git := mocks.NewMockGit(ctrl)
+			git.EXPECT().Init(gomock.Any()).Times(1).Return(nil)
+			git.EXPECT().Config(gomock.Any(), gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			tc.expect(git, pull)
+			if !tc.wantErr {
+				git.EXPECT().RevParse(gomock.Any(), pull.BaseRefName).Times(1).Return("sha", nil)
+				git.EXPECT().MergeBase(gomock.Any(), "sha", pull.Tip.OID).Times(1).Return("mergebase", nil)
+				git.EXPECT().Checkout(gomock.Any(), "sha", "sha").Times(1).Return(nil)
+				git.EXPECT().Merge(gomock.Any(), pull.Tip.OID, "", "").Times(1).Return(nil)
+				git.EXPECT().RevParse(gomock.Any(), "HEAD^{tree}").Times(1).Return("tree", nil)
+			}
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: version,
+				Params:  resource.GetParameters{CloneRetries: tc.retries},
+			}
+			_, err := resource.Get(input, github, git, dir)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestGetResolvesNodeIDByCommit(t *testing.T) {
+	tests := []struct {
+		description string
+		version     resource.Version
+		fallback    bool
+		wantErr     bool
+	}{
+		{
+			description: "falls back to the commit for a node id",
+			version:     resource.Version{PR: "MDExOlB1bGxSZXF1ZXN0MQ==", Commit: "oid1"},
+			fallback:    true,
+		},
+		{
+			description: "does not fall back for a pr number",
+			version:     resource.Version{PR: "1", Commit: "oid1"},
+			fallback:    false,
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			pull := createTestPR(1, false)
+
+			github := mocks.NewMockGithub(ctrl)
+			github.EXPECT().GetPullRequest(gomock.Any(), tc.version.PR, tc.version.Commit).Times(1).Return(nil, errors.New("could not resolve to a node"))
+			git := mocks.NewMockGit(ctrl)
+			if tc.fallback {
+				github.EXPECT().GetPullRequestByCommit(gomock.Any(), tc.version.Commit).Times(1).Return(pull, nil)
+				expectGet(git, pull, getExpectations{})
+			}
+
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+
+			input := resource.GetRequest{
+				Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+				Version: tc.version,
+			}
+			output, err := resource.Get(input, github, git, dir)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := output.Version, tc.version; !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
+
+func TestGetSquash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{squash: true})
+	git.EXPECT().Merge(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{IntegrationTool: "squash"},
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	metadata := readTestFile(t, filepath.Join(dir, ".git", "resource", "metadata.json"))
+	if want := `{"name":"merge_sha","value":"squashed"}`; !strings.Contains(metadata, want) {
+		t.Errorf("expected metadata to contain %s, got:\n%s", want, metadata)
+	}
+}
+
+func TestGetMalformedRepository(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	github := mocks.NewMockGithub(ctrl)
+	git := mocks.NewMockGit(ctrl)
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo-test-repository", AccessToken: "oauthtoken"},
+		Version: resource.Version{PR: "pr1", Commit: "commit1"},
+	}
+	if _, err := resource.Get(input, github, git, dir); err == nil {
+		t.Fatal("expected an error for a malformed repository")
+	}
+}
+
+func TestGetCloneDir(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	// The clone directory must exist before the repository is initialized.
+	expectGet(git, pull, getExpectations{init: func(context.Context) {
+		if _, err := os.Stat(filepath.Join(dir, "repo")); err != nil {
+			t.Errorf("expected clone directory to exist: %s", err)
+		}
+	}})
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{CloneDir: "repo"},
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Version and metadata are written at the top level for put.
+	versionString := readTestFile(t, filepath.Join(dir, ".git", "resource", "version.json"))
+	if got, want := versionString, `{"pr":"pr1","commit":"commit1","committed":"0001-01-01T00:00:00Z"}`; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repo", ".git", "resource")); !os.IsNotExist(err) {
+		t.Errorf("expected no metadata in the clone directory")
+	}
+}
+
+func TestGetParametersValidateCloneDir(t *testing.T) {
+	tests := []struct {
+		cloneDir string
+		wantErr  bool
+	}{
+		{cloneDir: "", wantErr: false},
+		{cloneDir: "repo", wantErr: false},
+		{cloneDir: "src/repo", wantErr: false},
+		{cloneDir: "/repo", wantErr: true},
+		{cloneDir: "../repo", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.cloneDir, func(t *testing.T) {
+			params := resource.GetParameters{CloneDir: tc.cloneDir}
+			if err := params.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetSparsePaths(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	version := resource.Version{PR: "pr1", Commit: "commit1", CommittedDate: time.Time{}}
+	paths := []string{"src/", "*.go"}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+
+	git := mocks.NewMockGit(ctrl)
+	expectGet(git, pull, getExpectations{sparsePaths: paths})
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	input := resource.GetRequest{
+		Source:  resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Version: version,
+		Params:  resource.GetParameters{SparsePaths: paths},
+	}
+	if _, err := resource.Get(input, github, git, dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
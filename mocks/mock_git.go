@@ -5,7 +5,9 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
+	io "io"
 	reflect "reflect"
 )
 
@@ -33,74 +35,160 @@ func (m *MockGit) EXPECT() *MockGitMockRecorder {
 }
 
 // Checkout mocks base method
-func (m *MockGit) Checkout(arg0 string) error {
-	ret := m.ctrl.Call(m, "Checkout", arg0)
+func (m *MockGit) Checkout(arg0 context.Context, arg1, arg2 string) error {
+	ret := m.ctrl.Call(m, "Checkout", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Checkout indicates an expected call of Checkout
-func (mr *MockGitMockRecorder) Checkout(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checkout", reflect.TypeOf((*MockGit)(nil).Checkout), arg0)
+func (mr *MockGitMockRecorder) Checkout(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checkout", reflect.TypeOf((*MockGit)(nil).Checkout), arg0, arg1, arg2)
+}
+
+// Config mocks base method
+func (m *MockGit) Config(arg0 context.Context, arg1, arg2 string) error {
+	ret := m.ctrl.Call(m, "Config", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Config indicates an expected call of Config
+func (mr *MockGitMockRecorder) Config(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Config", reflect.TypeOf((*MockGit)(nil).Config), arg0, arg1, arg2)
+}
+
+// ConflictedFiles mocks base method
+func (m *MockGit) ConflictedFiles(arg0 context.Context) ([]string, error) {
+	ret := m.ctrl.Call(m, "ConflictedFiles", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConflictedFiles indicates an expected call of ConflictedFiles
+func (mr *MockGitMockRecorder) ConflictedFiles(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConflictedFiles", reflect.TypeOf((*MockGit)(nil).ConflictedFiles), arg0)
+}
+
+// Diff mocks base method
+func (m *MockGit) Diff(arg0 context.Context, arg1, arg2 string, arg3 io.Writer) error {
+	ret := m.ctrl.Call(m, "Diff", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Diff indicates an expected call of Diff
+func (mr *MockGitMockRecorder) Diff(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Diff", reflect.TypeOf((*MockGit)(nil).Diff), arg0, arg1, arg2, arg3)
 }
 
 // Fetch mocks base method
-func (m *MockGit) Fetch(arg0 string, arg1 int) error {
-	ret := m.ctrl.Call(m, "Fetch", arg0, arg1)
+func (m *MockGit) Fetch(arg0 context.Context, arg1 string, arg2 int, arg3 []string) error {
+	ret := m.ctrl.Call(m, "Fetch", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Fetch indicates an expected call of Fetch
-func (mr *MockGitMockRecorder) Fetch(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockGit)(nil).Fetch), arg0, arg1)
+func (mr *MockGitMockRecorder) Fetch(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockGit)(nil).Fetch), arg0, arg1, arg2, arg3)
 }
 
 // Init mocks base method
-func (m *MockGit) Init() error {
-	ret := m.ctrl.Call(m, "Init")
+func (m *MockGit) Init(arg0 context.Context) error {
+	ret := m.ctrl.Call(m, "Init", arg0)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Init indicates an expected call of Init
-func (mr *MockGitMockRecorder) Init() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockGit)(nil).Init))
+func (mr *MockGitMockRecorder) Init(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockGit)(nil).Init), arg0)
 }
 
 // Merge mocks base method
-func (m *MockGit) Merge(arg0 string) error {
-	ret := m.ctrl.Call(m, "Merge", arg0)
+func (m *MockGit) Merge(arg0 context.Context, arg1, arg2, arg3 string) error {
+	ret := m.ctrl.Call(m, "Merge", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Merge indicates an expected call of Merge
-func (mr *MockGitMockRecorder) Merge(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockGit)(nil).Merge), arg0)
+func (mr *MockGitMockRecorder) Merge(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockGit)(nil).Merge), arg0, arg1, arg2, arg3)
+}
+
+// MergeBase mocks base method
+func (m *MockGit) MergeBase(arg0 context.Context, arg1, arg2 string) (string, error) {
+	ret := m.ctrl.Call(m, "MergeBase", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MergeBase indicates an expected call of MergeBase
+func (mr *MockGitMockRecorder) MergeBase(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeBase", reflect.TypeOf((*MockGit)(nil).MergeBase), arg0, arg1, arg2)
+}
+
+// MergeSquash mocks base method
+func (m *MockGit) MergeSquash(arg0 context.Context, arg1, arg2, arg3 string) error {
+	ret := m.ctrl.Call(m, "MergeSquash", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MergeSquash indicates an expected call of MergeSquash
+func (mr *MockGitMockRecorder) MergeSquash(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeSquash", reflect.TypeOf((*MockGit)(nil).MergeSquash), arg0, arg1, arg2, arg3)
 }
 
 // Pull mocks base method
-func (m *MockGit) Pull(arg0 string) error {
-	ret := m.ctrl.Call(m, "Pull", arg0)
+func (m *MockGit) Pull(arg0 context.Context, arg1 string) error {
+	ret := m.ctrl.Call(m, "Pull", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Pull indicates an expected call of Pull
-func (mr *MockGitMockRecorder) Pull(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pull", reflect.TypeOf((*MockGit)(nil).Pull), arg0)
+func (mr *MockGitMockRecorder) Pull(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pull", reflect.TypeOf((*MockGit)(nil).Pull), arg0, arg1)
 }
 
 // RevParse mocks base method
-func (m *MockGit) RevParse(arg0 string) (string, error) {
-	ret := m.ctrl.Call(m, "RevParse", arg0)
+func (m *MockGit) RevParse(arg0 context.Context, arg1 string) (string, error) {
+	ret := m.ctrl.Call(m, "RevParse", arg0, arg1)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // RevParse indicates an expected call of RevParse
-func (mr *MockGitMockRecorder) RevParse(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevParse", reflect.TypeOf((*MockGit)(nil).RevParse), arg0)
+func (mr *MockGitMockRecorder) RevParse(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevParse", reflect.TypeOf((*MockGit)(nil).RevParse), arg0, arg1)
+}
+
+// SparseCheckout mocks base method
+func (m *MockGit) SparseCheckout(arg0 context.Context, arg1 []string) error {
+	ret := m.ctrl.Call(m, "SparseCheckout", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SparseCheckout indicates an expected call of SparseCheckout
+func (mr *MockGitMockRecorder) SparseCheckout(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SparseCheckout", reflect.TypeOf((*MockGit)(nil).SparseCheckout), arg0, arg1)
+}
+
+// VerifyCommit mocks base method
+func (m *MockGit) VerifyCommit(arg0 context.Context, arg1 string) error {
+	ret := m.ctrl.Call(m, "VerifyCommit", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyCommit indicates an expected call of VerifyCommit
+func (mr *MockGitMockRecorder) VerifyCommit(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyCommit", reflect.TypeOf((*MockGit)(nil).VerifyCommit), arg0, arg1)
 }
@@ -5,8 +5,10 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	github_pr_resource "github.com/itsdalmo/github-pr-resource"
+	githubv4 "github.com/shurcooL/githubv4"
 	reflect "reflect"
 )
 
@@ -33,65 +35,269 @@ func (m *MockGithub) EXPECT() *MockGithubMockRecorder {
 	return m.recorder
 }
 
+// AddLabels mocks base method
+func (m *MockGithub) AddLabels(arg0 context.Context, arg1 int, arg2 []string) error {
+	ret := m.ctrl.Call(m, "AddLabels", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddLabels indicates an expected call of AddLabels
+func (mr *MockGithubMockRecorder) AddLabels(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLabels", reflect.TypeOf((*MockGithub)(nil).AddLabels), arg0, arg1, arg2)
+}
+
+// DeleteBranch mocks base method
+func (m *MockGithub) DeleteBranch(arg0 context.Context, arg1 string) error {
+	ret := m.ctrl.Call(m, "DeleteBranch", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBranch indicates an expected call of DeleteBranch
+func (mr *MockGithubMockRecorder) DeleteBranch(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBranch", reflect.TypeOf((*MockGithub)(nil).DeleteBranch), arg0, arg1)
+}
+
+// GetFileContent mocks base method
+func (m *MockGithub) GetFileContent(arg0 context.Context, arg1, arg2 string) (string, error) {
+	ret := m.ctrl.Call(m, "GetFileContent", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFileContent indicates an expected call of GetFileContent
+func (mr *MockGithubMockRecorder) GetFileContent(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileContent", reflect.TypeOf((*MockGithub)(nil).GetFileContent), arg0, arg1, arg2)
+}
+
 // GetPullRequest mocks base method
-func (m *MockGithub) GetPullRequest(arg0, arg1 string) (*github_pr_resource.PullRequest, error) {
-	ret := m.ctrl.Call(m, "GetPullRequest", arg0, arg1)
+func (m *MockGithub) GetPullRequest(arg0 context.Context, arg1, arg2 string) (*github_pr_resource.PullRequest, error) {
+	ret := m.ctrl.Call(m, "GetPullRequest", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*github_pr_resource.PullRequest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPullRequest indicates an expected call of GetPullRequest
-func (mr *MockGithubMockRecorder) GetPullRequest(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequest", reflect.TypeOf((*MockGithub)(nil).GetPullRequest), arg0, arg1)
+func (mr *MockGithubMockRecorder) GetPullRequest(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequest", reflect.TypeOf((*MockGithub)(nil).GetPullRequest), arg0, arg1, arg2)
+}
+
+// GetPullRequestByCommit mocks base method
+func (m *MockGithub) GetPullRequestByCommit(arg0 context.Context, arg1 string) (*github_pr_resource.PullRequest, error) {
+	ret := m.ctrl.Call(m, "GetPullRequestByCommit", arg0, arg1)
+	ret0, _ := ret[0].(*github_pr_resource.PullRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPullRequestByCommit indicates an expected call of GetPullRequestByCommit
+func (mr *MockGithubMockRecorder) GetPullRequestByCommit(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequestByCommit", reflect.TypeOf((*MockGithub)(nil).GetPullRequestByCommit), arg0, arg1)
+}
+
+// GetRepository mocks base method
+func (m *MockGithub) GetRepository(arg0 context.Context) (*github_pr_resource.RepositoryObject, error) {
+	ret := m.ctrl.Call(m, "GetRepository", arg0)
+	ret0, _ := ret[0].(*github_pr_resource.RepositoryObject)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRepository indicates an expected call of GetRepository
+func (mr *MockGithubMockRecorder) GetRepository(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepository", reflect.TypeOf((*MockGithub)(nil).GetRepository), arg0)
+}
+
+// GetRequiredStatusContexts mocks base method
+func (m *MockGithub) GetRequiredStatusContexts(arg0 context.Context, arg1 string) ([]string, error) {
+	ret := m.ctrl.Call(m, "GetRequiredStatusContexts", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRequiredStatusContexts indicates an expected call of GetRequiredStatusContexts
+func (mr *MockGithubMockRecorder) GetRequiredStatusContexts(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRequiredStatusContexts", reflect.TypeOf((*MockGithub)(nil).GetRequiredStatusContexts), arg0, arg1)
+}
+
+// GetStatusContexts mocks base method
+func (m *MockGithub) GetStatusContexts(arg0 context.Context, arg1 string) (map[string]string, error) {
+	ret := m.ctrl.Call(m, "GetStatusContexts", arg0, arg1)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatusContexts indicates an expected call of GetStatusContexts
+func (mr *MockGithubMockRecorder) GetStatusContexts(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusContexts", reflect.TypeOf((*MockGithub)(nil).GetStatusContexts), arg0, arg1)
+}
+
+// LatestRelease mocks base method
+func (m *MockGithub) LatestRelease(arg0 context.Context) (string, error) {
+	ret := m.ctrl.Call(m, "LatestRelease", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestRelease indicates an expected call of LatestRelease
+func (mr *MockGithubMockRecorder) LatestRelease(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestRelease", reflect.TypeOf((*MockGithub)(nil).LatestRelease), arg0)
+}
+
+// ListCommitMessages mocks base method
+func (m *MockGithub) ListCommitMessages(arg0 context.Context, arg1 int) ([]string, error) {
+	ret := m.ctrl.Call(m, "ListCommitMessages", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCommitMessages indicates an expected call of ListCommitMessages
+func (mr *MockGithubMockRecorder) ListCommitMessages(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommitMessages", reflect.TypeOf((*MockGithub)(nil).ListCommitMessages), arg0, arg1)
+}
+
+// ListLinkedIssues mocks base method
+func (m *MockGithub) ListLinkedIssues(arg0 context.Context, arg1 int) ([]github_pr_resource.LinkedIssue, error) {
+	ret := m.ctrl.Call(m, "ListLinkedIssues", arg0, arg1)
+	ret0, _ := ret[0].([]github_pr_resource.LinkedIssue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLinkedIssues indicates an expected call of ListLinkedIssues
+func (mr *MockGithubMockRecorder) ListLinkedIssues(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLinkedIssues", reflect.TypeOf((*MockGithub)(nil).ListLinkedIssues), arg0, arg1)
 }
 
 // ListModifiedFiles mocks base method
-func (m *MockGithub) ListModifiedFiles(arg0 int) ([]string, error) {
-	ret := m.ctrl.Call(m, "ListModifiedFiles", arg0)
+func (m *MockGithub) ListModifiedFiles(arg0 context.Context, arg1 int) ([]string, error) {
+	ret := m.ctrl.Call(m, "ListModifiedFiles", arg0, arg1)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListModifiedFiles indicates an expected call of ListModifiedFiles
-func (mr *MockGithubMockRecorder) ListModifiedFiles(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListModifiedFiles", reflect.TypeOf((*MockGithub)(nil).ListModifiedFiles), arg0)
+func (mr *MockGithubMockRecorder) ListModifiedFiles(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListModifiedFiles", reflect.TypeOf((*MockGithub)(nil).ListModifiedFiles), arg0, arg1)
+}
+
+// ListModifiedFilesPage mocks base method
+func (m *MockGithub) ListModifiedFilesPage(arg0 context.Context, arg1, arg2 int) ([]string, int, error) {
+	ret := m.ctrl.Call(m, "ListModifiedFilesPage", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListModifiedFilesPage indicates an expected call of ListModifiedFilesPage
+func (mr *MockGithubMockRecorder) ListModifiedFilesPage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListModifiedFilesPage", reflect.TypeOf((*MockGithub)(nil).ListModifiedFilesPage), arg0, arg1, arg2)
+}
+
+// ListParticipants mocks base method
+func (m *MockGithub) ListParticipants(arg0 context.Context, arg1 int) ([]string, error) {
+	ret := m.ctrl.Call(m, "ListParticipants", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListParticipants indicates an expected call of ListParticipants
+func (mr *MockGithubMockRecorder) ListParticipants(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListParticipants", reflect.TypeOf((*MockGithub)(nil).ListParticipants), arg0, arg1)
 }
 
-// ListOpenPullRequests mocks base method
-func (m *MockGithub) ListOpenPullRequests() ([]*github_pr_resource.PullRequest, error) {
-	ret := m.ctrl.Call(m, "ListOpenPullRequests")
+// ListPullRequests mocks base method
+func (m *MockGithub) ListPullRequests(arg0 context.Context, arg1 []githubv4.PullRequestState, arg2 int, arg3 *githubv4.IssueOrder) ([]*github_pr_resource.PullRequest, error) {
+	ret := m.ctrl.Call(m, "ListPullRequests", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].([]*github_pr_resource.PullRequest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListOpenPullRequests indicates an expected call of ListOpenPullRequests
-func (mr *MockGithubMockRecorder) ListOpenPullRequests() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOpenPullRequests", reflect.TypeOf((*MockGithub)(nil).ListOpenPullRequests))
+// ListPullRequests indicates an expected call of ListPullRequests
+func (mr *MockGithubMockRecorder) ListPullRequests(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPullRequests", reflect.TypeOf((*MockGithub)(nil).ListPullRequests), arg0, arg1, arg2, arg3)
+}
+
+// MergePullRequest mocks base method
+func (m *MockGithub) MergePullRequest(arg0 context.Context, arg1 int, arg2, arg3, arg4, arg5 string) error {
+	ret := m.ctrl.Call(m, "MergePullRequest", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MergePullRequest indicates an expected call of MergePullRequest
+func (mr *MockGithubMockRecorder) MergePullRequest(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergePullRequest", reflect.TypeOf((*MockGithub)(nil).MergePullRequest), arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
 // PostComment mocks base method
-func (m *MockGithub) PostComment(arg0, arg1 string) error {
-	ret := m.ctrl.Call(m, "PostComment", arg0, arg1)
+func (m *MockGithub) PostComment(arg0 context.Context, arg1, arg2 string) error {
+	ret := m.ctrl.Call(m, "PostComment", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // PostComment indicates an expected call of PostComment
-func (mr *MockGithubMockRecorder) PostComment(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostComment", reflect.TypeOf((*MockGithub)(nil).PostComment), arg0, arg1)
+func (mr *MockGithubMockRecorder) PostComment(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostComment", reflect.TypeOf((*MockGithub)(nil).PostComment), arg0, arg1, arg2)
+}
+
+// RemoveLabels mocks base method
+func (m *MockGithub) RemoveLabels(arg0 context.Context, arg1 int, arg2 []string) error {
+	ret := m.ctrl.Call(m, "RemoveLabels", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveLabels indicates an expected call of RemoveLabels
+func (mr *MockGithubMockRecorder) RemoveLabels(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveLabels", reflect.TypeOf((*MockGithub)(nil).RemoveLabels), arg0, arg1, arg2)
+}
+
+// SearchPullRequests mocks base method
+func (m *MockGithub) SearchPullRequests(arg0 context.Context, arg1 string, arg2 int) ([]*github_pr_resource.PullRequest, error) {
+	ret := m.ctrl.Call(m, "SearchPullRequests", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*github_pr_resource.PullRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchPullRequests indicates an expected call of SearchPullRequests
+func (mr *MockGithubMockRecorder) SearchPullRequests(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchPullRequests", reflect.TypeOf((*MockGithub)(nil).SearchPullRequests), arg0, arg1, arg2)
+}
+
+// SubmitReview mocks base method
+func (m *MockGithub) SubmitReview(arg0 context.Context, arg1 int, arg2, arg3, arg4 string) error {
+	ret := m.ctrl.Call(m, "SubmitReview", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SubmitReview indicates an expected call of SubmitReview
+func (mr *MockGithubMockRecorder) SubmitReview(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitReview", reflect.TypeOf((*MockGithub)(nil).SubmitReview), arg0, arg1, arg2, arg3, arg4)
 }
 
 // UpdateCommitStatus mocks base method
-func (m *MockGithub) UpdateCommitStatus(arg0, arg1, arg2 string) error {
-	ret := m.ctrl.Call(m, "UpdateCommitStatus", arg0, arg1, arg2)
+func (m *MockGithub) UpdateCommitStatus(arg0 context.Context, arg1, arg2, arg3 string) error {
+	ret := m.ctrl.Call(m, "UpdateCommitStatus", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateCommitStatus indicates an expected call of UpdateCommitStatus
-func (mr *MockGithubMockRecorder) UpdateCommitStatus(arg0, arg1, arg2 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCommitStatus", reflect.TypeOf((*MockGithub)(nil).UpdateCommitStatus), arg0, arg1, arg2)
+func (mr *MockGithubMockRecorder) UpdateCommitStatus(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCommitStatus", reflect.TypeOf((*MockGithub)(nil).UpdateCommitStatus), arg0, arg1, arg2, arg3)
 }
@@ -1,8 +1,14 @@
 package resource
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shurcooL/githubv4"
@@ -10,22 +16,114 @@ import (
 
 // Source represents the configuration for the resource.
 type Source struct {
-	Repository    string   `json:"repository"`
-	AccessToken   string   `json:"access_token"`
-	V3Endpoint    string   `json:"v3_endpoint"`
-	V4Endpoint    string   `json:"v4_endpoint"`
-	Paths         []string `json:"path"`
-	IgnorePaths   []string `json:"ignore_path"`
-	DisableCISkip string   `json:"disable_ci_skip"`
+	Repository             string            `json:"repository"`
+	AccessToken            string            `json:"access_token"`
+	V3Endpoint             string            `json:"v3_endpoint"`
+	V4Endpoint             string            `json:"v4_endpoint"`
+	APIVersion             string            `json:"api_version"`
+	Proxy                  string            `json:"proxy"`
+	States                 []string          `json:"states"`
+	GithubOrderBy          *PullRequestOrder `json:"github_order_by"`
+	Paths                  []string          `json:"path"`
+	IgnorePaths            []string          `json:"ignore_path"`
+	PathsSkipOnFirstRun    string            `json:"paths_skip_on_first_run"`
+	DisableCISkip          string            `json:"disable_ci_skip"`
+	Trace                  string            `json:"trace"`
+	Concurrency            int               `json:"concurrency"`
+	VersionKey             string            `json:"version_key"`
+	TriggerOnReopen        string            `json:"trigger_on_reopen"`
+	MaxCommitsPerPR        int               `json:"max_commits_per_pr"`
+	MinCommitAge           string            `json:"min_commit_age"`
+	LinkedIssueLabel       string            `json:"linked_issue_label"`
+	SkipArchived           string            `json:"skip_archived"`
+	DisableForks           string            `json:"disable_forks"`
+	BatchMode              string            `json:"batch_mode"`
+	WebhookOptimized       string            `json:"webhook_optimized"`
+	IgnoreLabels           []string          `json:"ignore_labels"`
+	SincePR                int               `json:"since_pr"`
+	SinceDate              string            `json:"since_date"`
+	StatusContextPrefix    string            `json:"status_context_prefix"`
+	RequireStatus          string            `json:"require_status"`
+	RateLimitWarnThreshold int               `json:"rate_limit_warn_threshold"`
+	MaxTrackedPRs          int               `json:"max_tracked_prs"`
+	Timeout                string            `json:"timeout"`
+	TitleRegex             string            `json:"title_regex"`
+	Repositories           []string          `json:"repositories"`
+	CacheDir               string            `json:"cache_dir"`
+	BackfillOnFirstRun     string            `json:"backfill_on_first_run"`
+	GitURLTemplate         string            `json:"git_url_template"`
+	LogFormat              string            `json:"log_format"`
+	RespectExportIgnore    string            `json:"respect_export_ignore"`
+	CheckSortKey           string            `json:"check_sort_key"`
+	SkipAutoMerge          string            `json:"skip_auto_merge"`
+	FailOnArchived         string            `json:"fail_on_archived"`
+	OnlyPRs                []int             `json:"only_prs"`
+	MinChangedFiles        int               `json:"min_changed_files"`
+	Milestone              string            `json:"milestone"`
+	QuietPeriod            string            `json:"quiet_period"`
+	UseNetrc               string            `json:"use_netrc"`
+	VerifyAccess           string            `json:"verify_access"`
+	CABundle               string            `json:"ca_bundle"`
+	RequireAssociation     []string          `json:"require_association"`
+	SearchQuery            string            `json:"search_query"`
+	SkipCIScanAllCommits   string            `json:"skip_ci_scan_all_commits"`
+	MaxCommits             int               `json:"max_commits"`
+	VersionStrategy        string            `json:"version_strategy"`
+	RequiredStatusChecks   []string          `json:"required_status_checks"`
+}
+
+// DefaultTimeout is the time allowed for all network operations of a single step.
+const DefaultTimeout = "10m"
+
+// ErrTimeout is returned when a network operation does not complete within the timeout.
+var ErrTimeout = errors.New("operation timed out")
+
+// Errors that classify why a check, get or put failed, for use with errors.Is.
+var (
+	// ErrAuth is returned when Github rejects the access token.
+	ErrAuth = errors.New("access token was rejected")
+	// ErrRateLimited is returned when the rate limit of the access token is exceeded.
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrRepoNotFound is returned when the repository does not exist (or cannot be read with the access token).
+	ErrRepoNotFound = errors.New("repository not found")
+	// ErrMergeConflict is returned by get when the pull request does not merge cleanly into the base.
+	ErrMergeConflict = errors.New("merge conflict")
+)
+
+// PullRequestOrder is the order in which pull requests are fetched from Github.
+type PullRequestOrder struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction"`
 }
 
 // Validate the source configuration.
 func (s *Source) Validate() error {
-	if s.AccessToken == "" {
-		return errors.New("access_token must be set")
+	config, err := s.parse()
+	if err != nil {
+		return err
+	}
+	if s.AccessToken == "" && !config.UseNetrc {
+		return errors.New("access_token must be set (or use_netrc enabled)")
+	}
+	if s.AccessToken == accessTokenEnvPrefix {
+		return errors.New("access_token must name an environment variable after env:")
 	}
-	if s.Repository == "" {
-		return errors.New("repository must be set")
+	if s.Repository == "" && len(s.Repositories) == 0 && s.SearchQuery == "" {
+		return errors.New("repository, repositories or search_query must be set")
+	}
+	if s.Repository != "" && len(s.Repositories) > 0 {
+		return errors.New("repository and repositories are mutually exclusive")
+	}
+	if s.SearchQuery != "" && (s.Repository != "" || len(s.Repositories) > 0) {
+		return errors.New("search_query and repository (or repositories) are mutually exclusive")
+	}
+	for _, r := range append([]string{s.Repository}, s.Repositories...) {
+		if _, _, err := parseRepository(r); r != "" && err != nil {
+			return errors.New("repository must be owner/repo or a repository URL")
+		}
+	}
+	if (len(s.Repositories) > 0 || s.SearchQuery != "") && (config.BatchMode || config.WebhookOptimized) {
+		return errors.New("batch_mode and webhook_optimized are not supported with repositories or search_query")
 	}
 	if s.V3Endpoint != "" && s.V4Endpoint == "" {
 		return errors.New("v4_endpoint must be set together with v3_endpoint")
@@ -33,9 +131,265 @@ func (s *Source) Validate() error {
 	if s.V4Endpoint != "" && s.V3Endpoint == "" {
 		return errors.New("v3_endpoint must be set together with v4_endpoint")
 	}
+	for _, endpoint := range []struct{ name, value string }{
+		{"v3_endpoint", s.V3Endpoint},
+		{"v4_endpoint", s.V4Endpoint},
+		{"proxy", s.Proxy},
+	} {
+		if _, err := url.Parse(endpoint.value); err != nil {
+			return fmt.Errorf("%s must be a valid URL: %s", endpoint.name, err)
+		}
+	}
+	if s.CABundle != "" {
+		if _, err := caCertPool(s.CABundle); err != nil {
+			return err
+		}
+	}
+	if s.Concurrency < 0 {
+		return errors.New("concurrency must not be negative")
+	}
+	if s.MaxTrackedPRs < 0 {
+		return errors.New("max_tracked_prs must not be negative")
+	}
+	if s.SincePR < 0 {
+		return errors.New("since_pr must not be negative")
+	}
+	if s.MinChangedFiles < 0 {
+		return errors.New("min_changed_files must not be negative")
+	}
+	for _, n := range s.OnlyPRs {
+		if n <= 0 {
+			return fmt.Errorf("only_prs must contain pull request numbers: %d", n)
+		}
+	}
+	for _, a := range s.RequireAssociation {
+		switch strings.ToUpper(a) {
+		case "COLLABORATOR", "CONTRIBUTOR", "FIRST_TIMER", "FIRST_TIME_CONTRIBUTOR", "MANNEQUIN", "MEMBER", "NONE", "OWNER":
+		default:
+			return fmt.Errorf("unknown author association in require_association: %s", a)
+		}
+	}
+	for _, c := range s.RequiredStatusChecks {
+		if c == "" {
+			return errors.New("required_status_checks must not contain empty contexts")
+		}
+	}
+	if s.MaxCommits < 0 {
+		return errors.New("max_commits must not be negative")
+	}
+	if s.MaxCommitsPerPR < 0 || s.MaxCommitsPerPR > 100 {
+		return errors.New("max_commits_per_pr must be between 1 and 100")
+	}
+	for _, state := range s.States {
+		switch strings.ToUpper(state) {
+		case "OPEN", "CLOSED", "MERGED":
+		default:
+			return fmt.Errorf("unknown state: %s", state)
+		}
+	}
+	if o := s.GithubOrderBy; o != nil {
+		switch strings.ToUpper(o.Field) {
+		case "CREATED_AT", "UPDATED_AT":
+		default:
+			return errors.New("github_order_by field must be one of: CREATED_AT, UPDATED_AT")
+		}
+		switch strings.ToUpper(o.Direction) {
+		case "", "ASC", "DESC":
+		default:
+			return errors.New("github_order_by direction must be one of: ASC, DESC")
+		}
+	}
+	switch strings.ToUpper(s.RequireStatus) {
+	case "", "SUCCESS", "FAILURE", "ERROR":
+	default:
+		return errors.New("require_status must be one of: SUCCESS, FAILURE, ERROR")
+	}
+	switch s.VersionKey {
+	case "", "committed", "updated":
+	default:
+		return errors.New("version_key must be one of: committed, updated")
+	}
+	switch s.VersionStrategy {
+	case "", "date", "sha":
+	default:
+		return errors.New("version_strategy must be one of: date, sha")
+	}
+	switch s.CheckSortKey {
+	case "", "date", "pr":
+	default:
+		return errors.New("check_sort_key must be one of: date, pr")
+	}
+	switch s.LogFormat {
+	case "", "text", "json":
+	default:
+		return errors.New("log_format must be one of: text, json")
+	}
+	if s.Timeout != "" {
+		if d, err := time.ParseDuration(s.Timeout); err != nil || d <= 0 {
+			return errors.New("timeout must be a positive duration")
+		}
+	}
 	return nil
 }
 
+// sourceConfig holds the options of a source that are given as strings, parsed
+// once by Validate (and check).
+type sourceConfig struct {
+	PathsSkipOnFirstRun  bool
+	DisableCISkip        bool
+	Trace                bool
+	TriggerOnReopen      bool
+	SkipArchived         bool
+	DisableForks         bool
+	BatchMode            bool
+	WebhookOptimized     bool
+	BackfillOnFirstRun   bool
+	RespectExportIgnore  bool
+	SkipAutoMerge        bool
+	FailOnArchived       bool
+	UseNetrc             bool
+	VerifyAccess         bool
+	SkipCIScanAllCommits bool
+	MinCommitAge         time.Duration
+	QuietPeriod          time.Duration
+	SinceDate            time.Time
+	TitleRegex           *regexp.Regexp
+}
+
+// parse the options of the source that are given as strings.
+func (s *Source) parse() (*sourceConfig, error) {
+	c := &sourceConfig{}
+	for _, flag := range []struct {
+		name, value string
+		parsed      *bool
+	}{
+		{"paths_skip_on_first_run", s.PathsSkipOnFirstRun, &c.PathsSkipOnFirstRun},
+		{"disable_ci_skip", s.DisableCISkip, &c.DisableCISkip},
+		{"trace", s.Trace, &c.Trace},
+		{"trigger_on_reopen", s.TriggerOnReopen, &c.TriggerOnReopen},
+		{"skip_archived", s.SkipArchived, &c.SkipArchived},
+		{"disable_forks", s.DisableForks, &c.DisableForks},
+		{"batch_mode", s.BatchMode, &c.BatchMode},
+		{"webhook_optimized", s.WebhookOptimized, &c.WebhookOptimized},
+		{"backfill_on_first_run", s.BackfillOnFirstRun, &c.BackfillOnFirstRun},
+		{"respect_export_ignore", s.RespectExportIgnore, &c.RespectExportIgnore},
+		{"skip_auto_merge", s.SkipAutoMerge, &c.SkipAutoMerge},
+		{"fail_on_archived", s.FailOnArchived, &c.FailOnArchived},
+		{"use_netrc", s.UseNetrc, &c.UseNetrc},
+		{"verify_access", s.VerifyAccess, &c.VerifyAccess},
+		{"skip_ci_scan_all_commits", s.SkipCIScanAllCommits, &c.SkipCIScanAllCommits},
+	} {
+		if flag.value == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(flag.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a boolean (as a string): %s", flag.name, flag.value)
+		}
+		*flag.parsed = b
+	}
+	var err error
+	if s.MinCommitAge != "" {
+		if c.MinCommitAge, err = time.ParseDuration(s.MinCommitAge); err != nil || c.MinCommitAge < 0 {
+			return nil, errors.New("min_commit_age must be a duration (e.g. 2m)")
+		}
+	}
+	if s.QuietPeriod != "" {
+		if c.QuietPeriod, err = time.ParseDuration(s.QuietPeriod); err != nil || c.QuietPeriod < 0 {
+			return nil, errors.New("quiet_period must be a duration (e.g. 10m)")
+		}
+	}
+	if s.SinceDate != "" {
+		if c.SinceDate, err = time.Parse(time.RFC3339, s.SinceDate); err != nil {
+			return nil, errors.New("since_date must be a date in RFC3339 format (e.g. 2018-05-14T00:00:00Z)")
+		}
+	}
+	if s.TitleRegex != "" {
+		if c.TitleRegex, err = regexp.Compile(s.TitleRegex); err != nil {
+			return nil, fmt.Errorf("failed to compile title_regex: %s", err)
+		}
+	}
+	return c, nil
+}
+
+// ValidateSource validates a source configuration the same way check, get and
+// put do, without running them (e.g. to lint the configuration of a pipeline).
+func ValidateSource(s Source) error {
+	return s.Validate()
+}
+
+// ApplyDefaults sets the default value of all unset (optional) fields.
+func (s *Source) ApplyDefaults() {
+	if s.APIVersion == "" {
+		s.APIVersion = DefaultAPIVersion
+	}
+	if len(s.States) == 0 {
+		s.States = []string{"OPEN"}
+	}
+	if s.Concurrency == 0 {
+		s.Concurrency = DefaultConcurrency
+	}
+	if s.VersionKey == "" {
+		s.VersionKey = "committed"
+	}
+	if s.MaxCommitsPerPR == 0 {
+		s.MaxCommitsPerPR = 1
+	}
+	if s.RateLimitWarnThreshold == 0 {
+		s.RateLimitWarnThreshold = DefaultRateLimitWarnThreshold
+	}
+	if s.Timeout == "" {
+		s.Timeout = DefaultTimeout
+	}
+	if s.LogFormat == "" {
+		s.LogFormat = "text"
+	}
+	// Archived repositories are skipped by default when checking more than one.
+	if s.SkipArchived == "" && (len(s.Repositories) > 0 || s.SearchQuery != "") {
+		s.SkipArchived = "true"
+	}
+}
+
+// timeoutContext returns a context that is cancelled when the time allowed for
+// all network operations of a single step has passed.
+func (s *Source) timeoutContext() (context.Context, context.CancelFunc, error) {
+	timeout := DefaultTimeout
+	if s.Timeout != "" {
+		timeout = s.Timeout
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse timeout: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	return ctx, cancel, nil
+}
+
+// ForVersion returns a copy of the source for the repository of the version,
+// which is set when checking multiple repositories (or a search query).
+func (s Source) ForVersion(v Version) Source {
+	if v.Repository != "" {
+		s.Repository = v.Repository
+		s.Repositories = nil
+		s.SearchQuery = ""
+	}
+	return s
+}
+
+// Redacted returns a copy of the source with secrets removed, which is safe to log.
+func (s Source) Redacted() Source {
+	if s.AccessToken != "" {
+		s.AccessToken = "REDACTED"
+	}
+	if proxy, err := url.Parse(s.Proxy); err == nil && proxy.User != nil {
+		if _, ok := proxy.User.Password(); ok {
+			proxy.User = url.UserPassword(proxy.User.Username(), "REDACTED")
+			s.Proxy = proxy.String()
+		}
+	}
+	return s
+}
+
 // Metadata output from get/put steps.
 type Metadata []*MetadataField
 
@@ -55,6 +409,8 @@ type Version struct {
 	PR            string    `json:"pr"`
 	Commit        string    `json:"commit"`
 	CommittedDate time.Time `json:"committed,omitempty"`
+	Batch         string    `json:"batch,omitempty"`
+	Repository    string    `json:"repository,omitempty"`
 }
 
 // NewVersion constructs a new Version.
@@ -66,24 +422,175 @@ func NewVersion(p *PullRequest) Version {
 	}
 }
 
+// NewBatchVersion constructs a Version covering all the given versions, which
+// are listed as pr:commit ordered by PR number (so the same PRs always give the
+// same batch). The committed date is the newest.
+func NewBatchVersion(versions []Version) Version {
+	// Parse the PR numbers once up front, rather than in every comparison.
+	type numbered struct {
+		Number int
+		Version
+	}
+	sorted := make([]numbered, len(versions))
+	size := 0
+	for i, v := range versions {
+		n, _ := strconv.Atoi(v.PR)
+		sorted[i] = numbered{Number: n, Version: v}
+		size += len(v.PR) + len(v.Commit) + 2
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Number < sorted[j].Number
+	})
+
+	var batch Version
+	var b strings.Builder
+	b.Grow(size)
+	for i, v := range sorted {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(v.PR)
+		b.WriteByte(':')
+		b.WriteString(v.Commit)
+		if v.CommittedDate.After(batch.CommittedDate) {
+			batch.CommittedDate = v.CommittedDate
+		}
+	}
+	batch.Batch = b.String()
+	return batch
+}
+
+// BatchVersions returns the versions of the pull requests listed in a batch version.
+func (v Version) BatchVersions() ([]Version, error) {
+	var versions []Version
+	for _, pull := range strings.Split(v.Batch, ",") {
+		parts := strings.Split(pull, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("malformed batch version: %s", pull)
+		}
+		versions = append(versions, Version{PR: parts[0], Commit: parts[1]})
+	}
+	return versions, nil
+}
+
+// versionDate returns the timestamp used to order versions of a pull request,
+// which is either the committed date of the tip (default) or when the pull
+// request was last updated.
+func versionDate(p *PullRequest, key string) time.Time {
+	if key == "updated" {
+		return p.UpdatedAt.Time
+	}
+	return p.Tip.CommittedDate.Time
+}
+
 // PullRequest represents a pull request and includes the tip (commit).
+// ReopenedAt is the time the pull request was last reopened (if ever),
+// LinkedIssues are the issues that will be closed by the pull request and
+// Labels are the names of the labels on the pull request.
 type PullRequest struct {
 	PullRequestObject
-	Tip CommitObject
+	Tip          CommitObject
+	ReopenedAt   githubv4.DateTime
+	LinkedIssues []LinkedIssue
+	Labels       []string
+}
+
+// LinkedIssue is an issue (and its labels) linked to a pull request.
+type LinkedIssue struct {
+	Number int
+	Labels []string
+}
+
+// HasLinkedIssueLabel returns true if any of the linked issues has the label.
+func (p *PullRequest) HasLinkedIssueLabel(label string) bool {
+	for _, i := range p.LinkedIssues {
+		for _, l := range i.Labels {
+			if strings.EqualFold(l, label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasAnyLabel returns true if the pull request has at least one of the labels.
+func (p *PullRequest) HasAnyLabel(labels []string) bool {
+	for _, l := range p.Labels {
+		for _, label := range labels {
+			if strings.EqualFold(l, label) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // PullRequestObject represents the GraphQL commit node.
 // https://developer.github.com/v4/object/commit/
 type PullRequestObject struct {
-	ID          string
-	Number      int
-	Title       string
-	URL         string
-	BaseRefName string
-	HeadRefName string
-	Repository  struct {
-		URL string
+	ID                string
+	Number            int
+	Title             string
+	URL               string
+	BaseRefName       string
+	HeadRefName       string
+	IsCrossRepository bool
+	IsDraft           bool
+	Mergeable         githubv4.MergeableState
+	CreatedAt         githubv4.DateTime
+	UpdatedAt         githubv4.DateTime
+	Repository        RepositoryObject
+	AutoMergeRequest  *AutoMergeRequestObject
+	Milestone         *MilestoneObject
+	AuthorAssociation githubv4.CommentAuthorAssociation
+	CommitCount       struct {
+		TotalCount int
+	} `graphql:"commitCount: commits"`
+}
+
+// HasAuthorAssociation returns true if the author of the pull request has one of
+// the associations with the repository (e.g. MEMBER), or if none are given.
+func (p PullRequestObject) HasAuthorAssociation(associations []string) bool {
+	if len(associations) == 0 {
+		return true
 	}
+	for _, a := range associations {
+		if strings.EqualFold(a, string(p.AuthorAssociation)) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasMilestone returns true if the pull request is attached to the milestone with the title.
+func (p PullRequestObject) HasMilestone(title string) bool {
+	return p.Milestone != nil && p.Milestone.Title == title
+}
+
+// QueuedForAutoMerge returns true if auto-merge is enabled for the pull request,
+// i.e. it will be merged (or enter the merge queue) once its checks pass.
+func (p PullRequestObject) QueuedForAutoMerge() bool {
+	return p.AutoMergeRequest != nil
+}
+
+// RepositoryObject represents the GraphQL repository node.
+// https://developer.github.com/v4/object/repository/
+type RepositoryObject struct {
+	URL           string
+	NameWithOwner string
+	IsArchived    bool
+}
+
+// MilestoneObject represents the GraphQL milestone node, which is null unless
+// the pull request is attached to a milestone.
+type MilestoneObject struct {
+	Title string
+}
+
+// AutoMergeRequestObject represents the GraphQL autoMergeRequest node, which
+// is null unless auto-merge has been enabled for the pull request.
+type AutoMergeRequestObject struct {
+	EnabledAt githubv4.DateTime
 }
 
 // CommitObject represents the GraphQL commit node.
@@ -94,8 +601,22 @@ type CommitObject struct {
 	CommittedDate githubv4.DateTime
 	Message       string
 	Author        struct {
-		User struct {
+		Name  string
+		Email string
+		User  struct {
 			Login string
 		}
 	}
+	StatusCheckRollup *struct {
+		State string
+	}
+}
+
+// Status returns the combined state of the statuses and checks on the commit,
+// or an empty string if there are none.
+func (c CommitObject) Status() string {
+	if c.StatusCheckRollup == nil {
+		return ""
+	}
+	return c.StatusCheckRollup.State
 }
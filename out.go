@@ -1,52 +1,85 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/shurcooL/githubv4"
 )
 
 // Put (business logic)
 func Put(request PutRequest, manager Github, inputDir string) (*PutResponse, error) {
+	ctx, cancel, err := request.Source.timeoutContext()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	response, err := put(ctx, request, manager, inputDir)
+	return response, classifyError(ctx, err)
+}
+
+// PutVersion reads the version written by the GET step in the input directory.
+// The version names the repository of the pull request when checking multiple
+// repositories (or a search query), so the manager for Put must be created
+// for request.Source.ForVersion(version).
+func PutVersion(request PutRequest, inputDir string) (Version, error) {
+	var version Version
+	content, err := ioutil.ReadFile(filepath.Join(inputDir, request.Params.Path, ".git", "resource", "version.json"))
+	if err != nil {
+		return version, fmt.Errorf("failed to read version from path: %w", err)
+	}
+	if err := json.Unmarshal(content, &version); err != nil {
+		return version, fmt.Errorf("failed to unmarshal version from file: %w", err)
+	}
+	return version, nil
+}
+
+func put(ctx context.Context, request PutRequest, manager Github, inputDir string) (*PutResponse, error) {
 	if err := request.Params.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid parameters: %s", err)
+		return nil, fmt.Errorf("invalid parameters: %w", err)
 	}
 	path := filepath.Join(inputDir, request.Params.Path, ".git", "resource")
 
 	// Version available after a GET step.
-	var version Version
-	content, err := ioutil.ReadFile(filepath.Join(path, "version.json"))
+	version, err := PutVersion(request, inputDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read version from path: %s", err)
+		return nil, err
 	}
-	if err := json.Unmarshal(content, &version); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal version from file: %s", err)
+	// A batch version has no single pull request to act on, but get writes the
+	// version of each one to a subdirectory named after its number.
+	if version.Batch != "" {
+		return nil, fmt.Errorf("cannot put to a batch version: set path to the subdirectory of a pull request (e.g. %s)", filepath.Join(request.Params.Path, "<pr>"))
 	}
 
 	// Metadata available after a GET step.
 	var metadata Metadata
-	content, err = ioutil.ReadFile(filepath.Join(path, "metadata.json"))
+	content, err := ioutil.ReadFile(filepath.Join(path, "metadata.json"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata from path: %s", err)
+		return nil, fmt.Errorf("failed to read metadata from path: %w", err)
 	}
 	if err := json.Unmarshal(content, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal metadata from file: %s", err)
+		return nil, fmt.Errorf("failed to unmarshal metadata from file: %w", err)
 	}
 
 	// Set status if specified
 	if status := request.Params.Status; status != "" {
-		if err := manager.UpdateCommitStatus(version.Commit, request.Params.Context, status); err != nil {
-			return nil, fmt.Errorf("failed to set status: %s", err)
+		statusContext := StatusContext(request.Source.StatusContextPrefix, request.Params.Context)
+		if err := manager.UpdateCommitStatus(ctx, version.Commit, statusContext, status); err != nil {
+			return nil, fmt.Errorf("failed to set status: %w", err)
 		}
 	}
 
 	// Set comment if specified
 	if comment := request.Params.Comment; comment != "" {
-		err = manager.PostComment(version.PR, comment)
+		err = manager.PostComment(ctx, version.PR, comment)
 		if err != nil {
-			return nil, fmt.Errorf("failed to post comment: %s", err)
+			return nil, fmt.Errorf("failed to post comment: %w", err)
 		}
 	}
 
@@ -55,13 +88,75 @@ func Put(request PutRequest, manager Github, inputDir string) (*PutResponse, err
 		path := filepath.Join(inputDir, request.Params.CommentFile)
 		content, err := ioutil.ReadFile(path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read comment file: %s", err)
+			return nil, fmt.Errorf("failed to read comment file: %w", err)
 		}
 		comment := string(content)
 		if comment != "" {
-			err = manager.PostComment(version.PR, comment)
+			err = manager.PostComment(ctx, version.PR, comment)
 			if err != nil {
-				return nil, fmt.Errorf("failed to post comment: %s", err)
+				return nil, fmt.Errorf("failed to post comment: %w", err)
+			}
+		}
+	}
+
+	// Add and remove labels if specified
+	if len(request.Params.AddLabels) > 0 || len(request.Params.RemoveLabels) > 0 {
+		pr, err := strconv.Atoi(version.PR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert pull request number to int: %w", err)
+		}
+		if len(request.Params.AddLabels) > 0 {
+			if err := manager.AddLabels(ctx, pr, request.Params.AddLabels); err != nil {
+				return nil, fmt.Errorf("failed to add labels: %w", err)
+			}
+		}
+		if len(request.Params.RemoveLabels) > 0 {
+			if err := manager.RemoveLabels(ctx, pr, request.Params.RemoveLabels); err != nil {
+				return nil, fmt.Errorf("failed to remove labels: %w", err)
+			}
+		}
+	}
+
+	// Submit a review if specified
+	if review := request.Params.Review; review != "" {
+		pr, err := strconv.Atoi(version.PR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert pull request number to int: %w", err)
+		}
+		if err := manager.SubmitReview(ctx, pr, version.Commit, strings.ToUpper(review), request.Params.ReviewBody); err != nil {
+			return nil, fmt.Errorf("failed to submit review: %w", err)
+		}
+	}
+
+	// Merge the pull request if specified
+	if method := request.Params.Merge; method != "" {
+		pr, err := strconv.Atoi(version.PR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert pull request number to int: %w", err)
+		}
+		if request.Params.MergeRequireMergeable {
+			pull, err := manager.GetPullRequest(ctx, version.PR, version.Commit)
+			if err != nil {
+				return nil, fmt.Errorf("failed to retrieve pull request: %w", err)
+			}
+			if pull.Mergeable != githubv4.MergeableStateMergeable {
+				return nil, fmt.Errorf("refusing to merge pull request #%d: mergeable state is %s", pr, pull.Mergeable)
+			}
+		}
+		if err := manager.MergePullRequest(ctx, pr, version.Commit, strings.ToLower(method), request.Params.MergeCommitTitle, request.Params.MergeCommitMessage); err != nil {
+			return nil, fmt.Errorf("failed to merge pull request: %w", err)
+		}
+	}
+
+	// Delete the head branch if specified (branches in forks are left alone)
+	if request.Params.DeleteBranch {
+		pull, err := manager.GetPullRequest(ctx, version.PR, version.Commit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve pull request: %w", err)
+		}
+		if !pull.IsCrossRepository {
+			if err := manager.DeleteBranch(ctx, pull.HeadRefName); err != nil {
+				return nil, fmt.Errorf("failed to delete branch: %w", err)
 			}
 		}
 	}
@@ -72,6 +167,18 @@ func Put(request PutRequest, manager Github, inputDir string) (*PutResponse, err
 	}, nil
 }
 
+// StatusContext returns the context of a commit status, which is the context
+// (defaults to status) namespaced by the prefix (defaults to concourse-ci).
+func StatusContext(prefix, context string) string {
+	if prefix == "" {
+		prefix = "concourse-ci"
+	}
+	if context == "" {
+		context = "status"
+	}
+	return prefix + "/" + context
+}
+
 // PutRequest ...
 type PutRequest struct {
 	Source Source        `json:"source"`
@@ -86,15 +193,38 @@ type PutResponse struct {
 
 // PutParameters for the resource.
 type PutParameters struct {
-	Path        string `json:"path"`
-	Context     string `json:"context"`
-	Status      string `json:"status"`
-	CommentFile string `json:"comment_file"`
-	Comment     string `json:"comment"`
+	Path                  string   `json:"path"`
+	Context               string   `json:"context"`
+	Status                string   `json:"status"`
+	CommentFile           string   `json:"comment_file"`
+	Comment               string   `json:"comment"`
+	AddLabels             []string `json:"add_labels"`
+	RemoveLabels          []string `json:"remove_labels"`
+	DeleteBranch          bool     `json:"delete_branch"`
+	Review                string   `json:"review"`
+	ReviewBody            string   `json:"review_body"`
+	Merge                 string   `json:"merge"`
+	MergeCommitTitle      string   `json:"merge_commit_title"`
+	MergeCommitMessage    string   `json:"merge_commit_message"`
+	MergeRequireMergeable bool     `json:"merge_require_mergeable"`
 }
 
 // Validate the put parameters.
 func (p *PutParameters) Validate() error {
+	switch strings.ToUpper(p.Review) {
+	case "", "APPROVE", "COMMENT":
+	case "REQUEST_CHANGES":
+		if p.ReviewBody == "" {
+			return errors.New("review_body must be set to request changes")
+		}
+	default:
+		return fmt.Errorf("unknown review: %s", p.Review)
+	}
+	switch strings.ToLower(p.Merge) {
+	case "", "merge", "squash", "rebase":
+	default:
+		return fmt.Errorf("unknown merge method: %s", p.Merge)
+	}
 	if p.Status == "" {
 		return nil
 	}
@@ -1,24 +1,29 @@
 package resource_test
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/itsdalmo/github-pr-resource"
 	"github.com/itsdalmo/github-pr-resource/mocks"
+	"github.com/shurcooL/githubv4"
 )
 
 func TestPut(t *testing.T) {
 
 	tests := []struct {
-		description string
-		source      resource.Source
-		version     resource.Version
-		parameters  resource.PutParameters
-		pullRequest *resource.PullRequest
+		description   string
+		source        resource.Source
+		version       resource.Version
+		parameters    resource.PutParameters
+		pullRequest   *resource.PullRequest
+		statusContext string
 	}{
 		{
 			description: "put with no parameters does nothing",
@@ -49,7 +54,8 @@ func TestPut(t *testing.T) {
 			parameters: resource.PutParameters{
 				Status: "success",
 			},
-			pullRequest: createTestPR(1, false),
+			pullRequest:   createTestPR(1, false),
+			statusContext: "concourse-ci/status",
 		},
 
 		{
@@ -67,7 +73,47 @@ func TestPut(t *testing.T) {
 				Status:  "failure",
 				Context: "build",
 			},
-			pullRequest: createTestPR(1, false),
+			pullRequest:   createTestPR(1, false),
+			statusContext: "concourse-ci/build",
+		},
+
+		{
+			description: "we can provide a prefix for the status context",
+			source: resource.Source{
+				Repository:          "itsdalmo/test-repository",
+				AccessToken:         "oauthtoken",
+				StatusContextPrefix: "myteam",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				Status: "success",
+			},
+			pullRequest:   createTestPR(1, false),
+			statusContext: "myteam/status",
+		},
+
+		{
+			description: "we can combine a prefix and a custom context",
+			source: resource.Source{
+				Repository:          "itsdalmo/test-repository",
+				AccessToken:         "oauthtoken",
+				StatusContextPrefix: "myteam",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				Status:  "success",
+				Context: "build",
+			},
+			pullRequest:   createTestPR(1, false),
+			statusContext: "myteam/build",
 		},
 
 		{
@@ -86,6 +132,148 @@ func TestPut(t *testing.T) {
 			},
 			pullRequest: createTestPR(1, false),
 		},
+
+		{
+			description: "we can add and remove labels on the pull request",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				AddLabels:    []string{"ci-passed"},
+				RemoveLabels: []string{"ci-failed", "ci-running"},
+			},
+			pullRequest: createTestPR(1, false),
+		},
+
+		{
+			description: "we can delete the branch of the pull request",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				DeleteBranch: true,
+			},
+			pullRequest: createTestPR(1, false),
+		},
+
+		{
+			description: "we do not delete the branch of a pull request from a fork",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "pr1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				DeleteBranch: true,
+			},
+			pullRequest: createTestFork(1),
+		},
+
+		{
+			description: "we can approve the pull request",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				Review: "APPROVE",
+			},
+			pullRequest: createTestPR(1, false),
+		},
+
+		{
+			description: "we can request changes on the pull request",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				Review:     "REQUEST_CHANGES",
+				ReviewBody: "please fix the build",
+			},
+			pullRequest: createTestPR(1, false),
+		},
+
+		{
+			description: "we can submit a review comment on the pull request",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				Review:     "COMMENT",
+				ReviewBody: "looks good to the bot",
+			},
+			pullRequest: createTestPR(1, false),
+		},
+
+		{
+			description: "we can squash merge the pull request",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				Merge:              "SQUASH",
+				MergeCommitTitle:   "Add feature (#1)",
+				MergeCommitMessage: "Merged by concourse",
+			},
+			pullRequest: createTestPR(1, false),
+		},
+
+		{
+			description: "we can merge a pull request that is mergeable",
+			source: resource.Source{
+				Repository:  "itsdalmo/test-repository",
+				AccessToken: "oauthtoken",
+			},
+			version: resource.Version{
+				PR:            "1",
+				Commit:        "commit1",
+				CommittedDate: time.Time{},
+			},
+			parameters: resource.PutParameters{
+				Merge:                 "rebase",
+				MergeRequireMergeable: true,
+			},
+			pullRequest: createTestMergeable(1),
+		},
 	}
 
 	for _, tc := range tests {
@@ -94,16 +282,21 @@ func TestPut(t *testing.T) {
 			defer ctrl.Finish()
 
 			github := mocks.NewMockGithub(ctrl)
-			github.EXPECT().GetPullRequest(tc.version.PR, tc.version.Commit).Times(1).Return(tc.pullRequest, nil)
+			github.EXPECT().GetPullRequest(gomock.Any(), tc.version.PR, tc.version.Commit).Times(1).Return(tc.pullRequest, nil)
 
 			git := mocks.NewMockGit(ctrl)
 			gomock.InOrder(
-				git.EXPECT().Init().Times(1).Return(nil),
-				git.EXPECT().Pull(tc.pullRequest.Repository.URL).Times(1).Return(nil),
-				git.EXPECT().Fetch(tc.pullRequest.Repository.URL, tc.pullRequest.Number).Times(1).Return(nil),
-				git.EXPECT().RevParse(tc.pullRequest.BaseRefName).Times(1).Return("sha", nil),
-				git.EXPECT().Checkout("sha").Times(1).Return(nil),
-				git.EXPECT().Merge(tc.pullRequest.Tip.OID).Times(1).Return(nil),
+				git.EXPECT().Init(gomock.Any()).Times(1).Return(nil),
+				git.EXPECT().Config(gomock.Any(), "user.name", "concourse-ci").Times(1).Return(nil),
+				git.EXPECT().Config(gomock.Any(), "user.email", "concourse@local").Times(1).Return(nil),
+				git.EXPECT().Pull(gomock.Any(), tc.pullRequest.Repository.URL).Times(1).Return(nil),
+				git.EXPECT().Fetch(gomock.Any(), tc.pullRequest.Repository.URL, tc.pullRequest.Number, nil).Times(1).Return(nil),
+				git.EXPECT().VerifyCommit(gomock.Any(), tc.pullRequest.Tip.OID).Times(1).Return(nil),
+				git.EXPECT().RevParse(gomock.Any(), tc.pullRequest.BaseRefName).Times(1).Return("sha", nil),
+				git.EXPECT().MergeBase(gomock.Any(), "sha", tc.pullRequest.Tip.OID).Times(1).Return("mergebase", nil),
+				git.EXPECT().Checkout(gomock.Any(), "sha", "sha").Times(1).Return(nil),
+				git.EXPECT().Merge(gomock.Any(), tc.pullRequest.Tip.OID, "", "").Times(1).Return(nil),
+				git.EXPECT().RevParse(gomock.Any(), "HEAD^{tree}").Times(1).Return("tree", nil),
 			)
 
 			dir := createTestDirectory(t)
@@ -118,10 +311,33 @@ func TestPut(t *testing.T) {
 
 			// Set expectations
 			if tc.parameters.Status != "" {
-				github.EXPECT().UpdateCommitStatus(tc.version.Commit, tc.parameters.Context, tc.parameters.Status).Times(1).Return(nil)
+				github.EXPECT().UpdateCommitStatus(gomock.Any(), tc.version.Commit, tc.statusContext, tc.parameters.Status).Times(1).Return(nil)
 			}
 			if tc.parameters.Comment != "" {
-				github.EXPECT().PostComment(tc.version.PR, tc.parameters.Comment).Times(1).Return(nil)
+				github.EXPECT().PostComment(gomock.Any(), tc.version.PR, tc.parameters.Comment).Times(1).Return(nil)
+			}
+			if len(tc.parameters.AddLabels) > 0 {
+				github.EXPECT().AddLabels(gomock.Any(), tc.pullRequest.Number, tc.parameters.AddLabels).Times(1).Return(nil)
+			}
+			if len(tc.parameters.RemoveLabels) > 0 {
+				github.EXPECT().RemoveLabels(gomock.Any(), tc.pullRequest.Number, tc.parameters.RemoveLabels).Times(1).Return(nil)
+			}
+			if tc.parameters.Review != "" {
+				github.EXPECT().SubmitReview(gomock.Any(), tc.pullRequest.Number, tc.version.Commit, tc.parameters.Review, tc.parameters.ReviewBody).Times(1).Return(nil)
+			}
+			if tc.parameters.Merge != "" {
+				if tc.parameters.MergeRequireMergeable {
+					github.EXPECT().GetPullRequest(gomock.Any(), tc.version.PR, tc.version.Commit).Times(1).Return(tc.pullRequest, nil)
+				}
+				github.EXPECT().MergePullRequest(gomock.Any(), tc.pullRequest.Number, tc.version.Commit, strings.ToLower(tc.parameters.Merge), tc.parameters.MergeCommitTitle, tc.parameters.MergeCommitMessage).Times(1).Return(nil)
+			}
+			if tc.parameters.DeleteBranch {
+				github.EXPECT().GetPullRequest(gomock.Any(), tc.version.PR, tc.version.Commit).Times(1).Return(tc.pullRequest, nil)
+				if tc.pullRequest.IsCrossRepository {
+					github.EXPECT().DeleteBranch(gomock.Any(), gomock.Any()).Times(0)
+				} else {
+					github.EXPECT().DeleteBranch(gomock.Any(), tc.pullRequest.HeadRefName).Times(1).Return(nil)
+				}
 			}
 
 			// Run put and verify output
@@ -136,3 +352,182 @@ func TestPut(t *testing.T) {
 		})
 	}
 }
+
+func createTestFork(count int) *resource.PullRequest {
+	pull := createTestPR(count, false)
+	pull.IsCrossRepository = true
+	return pull
+}
+
+func createTestMergeable(count int) *resource.PullRequest {
+	pull := createTestPR(count, false)
+	pull.Mergeable = githubv4.MergeableStateMergeable
+	return pull
+}
+
+func TestPutMergeRequireMergeable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pull := createTestPR(1, false)
+	pull.Mergeable = githubv4.MergeableStateConflicting
+	version := resource.Version{PR: "1", Commit: "commit1"}
+
+	github := mocks.NewMockGithub(ctrl)
+	github.EXPECT().GetPullRequest(gomock.Any(), version.PR, version.Commit).Times(1).Return(pull, nil)
+	github.EXPECT().MergePullRequest(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	// Write the version and metadata of a previous get.
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pull-request", ".git", "resource")
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		t.Fatalf("failed to create resource directory: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "version.json"), []byte(`{"pr":"1","commit":"commit1"}`), 0644); err != nil {
+		t.Fatalf("failed to write version: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "metadata.json"), []byte(`[]`), 0644); err != nil {
+		t.Fatalf("failed to write metadata: %s", err)
+	}
+
+	input := resource.PutRequest{
+		Source: resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Params: resource.PutParameters{Path: "pull-request", Merge: "merge", MergeRequireMergeable: true},
+	}
+	_, err := resource.Put(input, github, dir)
+	if want := "refusing to merge pull request #1: mergeable state is CONFLICTING"; err == nil || err.Error() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, want)
+	}
+}
+
+func TestPutBatchVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing is done to any of the pull requests in the batch.
+	github := mocks.NewMockGithub(ctrl)
+
+	// Write the version and metadata of a previous get of a batch.
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pull-requests", ".git", "resource")
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		t.Fatalf("failed to create resource directory: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "version.json"), []byte(`{"pr":"","commit":"","batch":"1:oid1,2:oid2"}`), 0644); err != nil {
+		t.Fatalf("failed to write version: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "metadata.json"), []byte(`[{"name":"prs","value":"1,2"}]`), 0644); err != nil {
+		t.Fatalf("failed to write metadata: %s", err)
+	}
+
+	input := resource.PutRequest{
+		Source: resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+		Params: resource.PutParameters{Path: "pull-requests", Status: "success", Comment: "done", Merge: "merge"},
+	}
+	_, err := resource.Put(input, github, dir)
+	if want := "cannot put to a batch version: set path to the subdirectory of a pull request (e.g. pull-requests/<pr>)"; err == nil || err.Error() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, want)
+	}
+}
+
+func TestPutVersionRepository(t *testing.T) {
+	tests := []struct {
+		description string
+		source      resource.Source
+		version     string
+		want        string
+	}{
+		{
+			description: "uses the repository of the source",
+			source:      resource.Source{Repository: "itsdalmo/test-repository", AccessToken: "oauthtoken"},
+			version:     `{"pr":"1","commit":"commit1"}`,
+			want:        "itsdalmo/test-repository",
+		},
+		{
+			description: "uses the repository of the version with repositories",
+			source:      resource.Source{Repositories: []string{"itsdalmo/api", "itsdalmo/web"}, AccessToken: "oauthtoken"},
+			version:     `{"pr":"1","commit":"commit1","repository":"itsdalmo/web"}`,
+			want:        "itsdalmo/web",
+		},
+		{
+			description: "uses the repository of the version with a search query",
+			source:      resource.Source{SearchQuery: "org:itsdalmo", AccessToken: "oauthtoken"},
+			version:     `{"pr":"1","commit":"commit1","repository":"itsdalmo/api"}`,
+			want:        "itsdalmo/api",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			dir := createTestDirectory(t)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "pull-request", ".git", "resource")
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				t.Fatalf("failed to create resource directory: %s", err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(path, "version.json"), []byte(tc.version), 0644); err != nil {
+				t.Fatalf("failed to write version: %s", err)
+			}
+
+			input := resource.PutRequest{Source: tc.source, Params: resource.PutParameters{Path: "pull-request"}}
+			version, err := resource.PutVersion(input, dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			// The manager for put is created from this source.
+			source := input.Source.ForVersion(version)
+			if err := source.Validate(); err != nil {
+				t.Fatalf("invalid source for version: %s", err)
+			}
+			if got := source.Repository; got != tc.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPutParametersValidateReview(t *testing.T) {
+	tests := []struct {
+		description string
+		parameters  resource.PutParameters
+		wantErr     bool
+	}{
+		{
+			description: "approves without a body",
+			parameters:  resource.PutParameters{Review: "APPROVE"},
+		},
+		{
+			description: "requests changes with a body",
+			parameters:  resource.PutParameters{Review: "REQUEST_CHANGES", ReviewBody: "please fix the build"},
+		},
+		{
+			description: "requires a body to request changes",
+			parameters:  resource.PutParameters{Review: "REQUEST_CHANGES"},
+			wantErr:     true,
+		},
+		{
+			description: "rejects an unknown review",
+			parameters:  resource.PutParameters{Review: "MERGE"},
+			wantErr:     true,
+		},
+		{
+			description: "squashes the pull request",
+			parameters:  resource.PutParameters{Merge: "squash"},
+		},
+		{
+			description: "rejects an unknown merge method",
+			parameters:  resource.PutParameters{Merge: "fast-forward"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			if err := tc.parameters.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}